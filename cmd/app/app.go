@@ -7,13 +7,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 
 	"github.com/d2iq-labs/csi-driver-trusted-ca/cmd/app/options"
 	csiapi "github.com/d2iq-labs/csi-driver-trusted-ca/pkg/apis/v1alpha1"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/driver"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/linuxtls"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/manager"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/pki"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/registry"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/source"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/storage"
 )
@@ -42,25 +47,117 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				return fmt.Errorf("failed to setup filesystem: %w", err)
 			}
 			store.FSGroupVolumeAttributeKey = csiapi.FSGroupKey
+			store.LinuxTLS = linuxtls.Options{
+				JavaTruststorePassword: opts.JavaTruststorePassword,
+				JavaJKSTruststore:      opts.JavaJKSTruststore,
+				LegacySubjectHash:      opts.LegacySubjectHash,
+			}
 
-			certSource, err := source.New(opts.TrustedCertsSource, opts.RestConfig)
+			collisionPolicy, err := source.ParseCollisionPolicy(opts.TrustedCertsCollisionPolicy)
+			if err != nil {
+				return err
+			}
+			allowlist, err := source.ParseAllowlist(opts.VolumeSourceAllowlist)
+			if err != nil {
+				return err
+			}
+			bundleCache, err := registry.NewBundleCache(filepath.Join(opts.DataRoot, "oci-cache"))
+			if err != nil {
+				return err
+			}
+			sourceFactory := source.NewFactory(
+				opts.RestConfig,
+				source.FactoryOptRegistryOptions(
+					registry.ClientOptBundleCache(bundleCache),
+					registry.ClientOptLogger(opts.Logr.WithName("oci")),
+					registry.ClientOptCAFile(opts.OCICAFile),
+					registry.ClientOptInsecureHosts(opts.OCIInsecureRegistries...),
+					registry.ClientOptPlainHTTPHosts(opts.OCIPlainHTTPRegistries...),
+					registry.ClientOptHostsDir(opts.OCIHostsDir),
+				),
+				source.FactoryOptHostPathOptions(source.HostPathOptions{
+					Root:      opts.HostPathRoot,
+					Allowlist: opts.HostPathAllowlist,
+				}),
+			)
+			defaultSource, err := sourceFactory.NewComposite(opts.TrustedCertsSources, collisionPolicy)
 			if err != nil {
 				return fmt.Errorf("failed to create cert source: %w", err)
 			}
+			certSource := source.NewVolumeSource(sourceFactory, defaultSource, allowlist)
+
+			if r, ok := certSource.(source.Runnable); ok {
+				log.Info("starting cert source")
+				if err := r.Start(ctx); err != nil {
+					return fmt.Errorf("failed to start cert source: %w", err)
+				}
+			}
+
+			normalizer := pki.NewNormalizer(opts.Logr.WithName("normalization"), pki.NormalizationOptions{
+				PKCS12Passwords: opts.PKCS12Passwords,
+			})
+			validator := pki.NewValidator(opts.Logr.WithName("validation"), pki.ValidationOptions{
+				DropExpired:   opts.DropExpiredCertificates,
+				DropNonCA:     opts.DropNonCACertificates,
+				MaxBundleSize: opts.MaxBundleSize,
+			})
+			getCertificates := func(ctx context.Context, meta metadata.Metadata) (map[string][]byte, error) {
+				files, err := certSource.GetFiles(ctx, meta)
+				if err != nil {
+					return nil, err
+				}
+				files, err = normalizer.Normalize(files)
+				if err != nil {
+					return nil, err
+				}
+				return validator.Validate(files)
+			}
+
+			var releaseVolume manager.ReleaseVolumeFunc
+			if r, ok := certSource.(source.VolumeReleaser); ok {
+				releaseVolume = r.ReleaseVolume
+			}
 
 			mngrlog := opts.Logr.WithName("manager")
+			mngr := manager.NewManagerOrDie(manager.Options{
+				MetadataReader:    store,
+				MetadataWriter:    store,
+				Log:               &mngrlog,
+				NodeID:            opts.NodeID,
+				GetCertificates:   getCertificates,
+				WriteCertificates: store.WriteFiles,
+				ReleaseVolume:     releaseVolume,
+				RefreshInterval:   opts.RefreshInterval,
+				RefreshJitter:     opts.RefreshJitter,
+			})
+
+			if n, ok := certSource.(source.Notifier); ok {
+				go func() {
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-n.Changes():
+							vn, ok := n.(source.VolumeNotifier)
+							if !ok {
+								log.Info("cert source changed, refreshing volumes")
+								mngr.RefreshAll()
+								continue
+							}
+							volumeIDs := vn.ChangedVolumes()
+							log.Info("cert source changed, refreshing volumes", "volume_ids", volumeIDs)
+							mngr.RefreshVolumes(volumeIDs...)
+						}
+					}
+				}()
+			}
+
 			d, err := driver.New(opts.Endpoint, opts.Logr.WithName("driver"), &driver.Options{
 				DriverName:    opts.DriverName,
 				DriverVersion: "v0.3.0",
 				NodeID:        opts.NodeID,
 				Store:         store,
-				Manager: manager.NewManagerOrDie(manager.Options{
-					MetadataReader:    store,
-					Log:               &mngrlog,
-					NodeID:            opts.NodeID,
-					GetCertificates:   certSource.GetFiles,
-					WriteCertificates: store.WriteFiles,
-				}),
+				Manager:       mngr,
 			})
 			if err != nil {
 				return errors.New("failed to setup driver: " + err.Error())
@@ -6,6 +6,7 @@ package options
 import (
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
@@ -18,6 +19,10 @@ import (
 	"k8s.io/klog/v2/klogr"
 
 	csiapi "github.com/d2iq-labs/csi-driver-trusted-ca/pkg/apis/v1alpha1"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/linuxtls"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/manager"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/pki"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/source"
 )
 
 // Options are the main options for the driver. Populated via processing
@@ -50,8 +55,78 @@ type Options struct {
 	// API.
 	RestConfig *rest.Config
 
-	// TrustedCertsSource is the source of the trusted certs.
-	TrustedCertsSource string
+	// TrustedCertsSources are the sources of the trusted certs. Files from all
+	// sources are combined into each volume.
+	TrustedCertsSources []string
+
+	// TrustedCertsCollisionPolicy determines how files with the same name from
+	// different sources are handled.
+	TrustedCertsCollisionPolicy string
+
+	// DropExpiredCertificates drops expired or not yet valid certificates
+	// instead of writing them to volumes.
+	DropExpiredCertificates bool
+
+	// DropNonCACertificates drops certificates that are not CA certificates
+	// instead of writing them to volumes.
+	DropNonCACertificates bool
+
+	// PKCS12Passwords are the passwords tried to decode PKCS#12 files
+	// retrieved from the trusted certs sources.
+	PKCS12Passwords []string
+
+	// MaxBundleSize is the maximum total size in bytes of the trusted
+	// certificates written to each volume.
+	MaxBundleSize int
+
+	// JavaTruststorePassword is the password of the Java truststores written
+	// to volumes.
+	JavaTruststorePassword string
+
+	// JavaJKSTruststore enables writing a legacy JKS truststore to volumes in
+	// addition to the PKCS#12 truststore.
+	JavaJKSTruststore bool
+
+	// LegacySubjectHash enables creating symlinks named after the legacy
+	// OpenSSL subject hash in addition to the current subject hash.
+	LegacySubjectHash bool
+
+	// OCICAFile is the path to a PEM file of CA certificates trusted in
+	// addition to the system CA certificates when pulling from OCI registries.
+	OCICAFile string
+
+	// OCIInsecureRegistries are the OCI registry hosts whose TLS certificates
+	// are not verified.
+	OCIInsecureRegistries []string
+
+	// OCIPlainHTTPRegistries are the OCI registry hosts accessed via plain
+	// HTTP.
+	OCIPlainHTTPRegistries []string
+
+	// OCIHostsDir is the path to a directory of containerd registry host
+	// configurations used to pull from mirrors when pulling from OCI
+	// registries.
+	OCIHostsDir string
+
+	// HostPathRoot is the directory the node filesystem is mounted at, read
+	// by the hostpath source.
+	HostPathRoot string
+
+	// HostPathAllowlist are the paths on the node that the hostpath source
+	// may read from.
+	HostPathAllowlist []string
+
+	// VolumeSourceAllowlist controls which sources pods in which namespaces
+	// may select via volume attributes.
+	VolumeSourceAllowlist []string
+
+	// RefreshInterval is the interval at which trusted certs are retrieved
+	// again for each mounted volume.
+	RefreshInterval time.Duration
+
+	// RefreshJitter is the maximum factor of RefreshInterval randomly added to
+	// each refresh interval.
+	RefreshJitter float64
 }
 
 func New() *Options {
@@ -123,6 +198,71 @@ func (o *Options) addAppFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.DataRoot, "data-root", "/csi-data-dir",
 		"The directory that the driver will write and mount volumes from.")
 
-	fs.StringVar(&o.TrustedCertsSource, "trusted-certs-source", "configmap::kube-system/ca-certs",
-		"The source for the trusted certificates.")
+	fs.StringArrayVar(&o.TrustedCertsSources, "trusted-certs-source", []string{"configmap::kube-system/ca-certs"},
+		"The source for the trusted certificates. Can be specified multiple times to combine sources.")
+
+	fs.StringVar(&o.TrustedCertsCollisionPolicy, "trusted-certs-collision-policy", string(source.CollisionPolicyError),
+		fmt.Sprintf(
+			"How files with the same name from multiple trusted certificate sources are handled. One of %q.",
+			source.CollisionPolicies,
+		))
+
+	fs.BoolVar(&o.DropExpiredCertificates, "drop-expired-certificates", false,
+		"Drop expired or not yet valid certificates retrieved from the trusted certificates sources.")
+
+	fs.BoolVar(&o.DropNonCACertificates, "drop-non-ca-certificates", false,
+		"Drop certificates retrieved from the trusted certificates sources that are not CA certificates.")
+
+	fs.StringArrayVar(&o.PKCS12Passwords, "pkcs12-password", []string{linuxtls.DefaultJavaTruststorePassword},
+		"A password tried to decode PKCS#12 files retrieved from the trusted certificates sources, in addition to the "+
+			"empty password. Can be specified multiple times.")
+
+	fs.IntVar(&o.MaxBundleSize, "max-bundle-size", pki.DefaultMaxBundleSize,
+		"The maximum total size in bytes of the trusted certificates written to each volume. 0 disables the limit.")
+
+	fs.StringVar(&o.JavaTruststorePassword, "java-truststore-password", linuxtls.DefaultJavaTruststorePassword,
+		"The password of the Java truststores written to volumes.")
+
+	fs.BoolVar(&o.JavaJKSTruststore, "java-jks-truststore", false,
+		"Write a legacy JKS truststore to volumes in addition to the PKCS#12 truststore.")
+
+	fs.BoolVar(&o.LegacySubjectHash, "legacy-subject-hash", false,
+		"Create symlinks named after the subject hash used by OpenSSL before 1.0.0 in addition to the current subject hash.")
+
+	fs.StringVar(&o.OCICAFile, "oci-ca-file", "",
+		"Path to a PEM file of CA certificates trusted in addition to the system CA certificates "+
+			"when pulling from OCI registries.")
+
+	fs.StringSliceVar(&o.OCIInsecureRegistries, "oci-insecure-registries", nil,
+		"OCI registry hosts, as host[:port], whose TLS certificates are not verified.")
+
+	fs.StringSliceVar(&o.OCIPlainHTTPRegistries, "oci-plain-http-registries", nil,
+		"OCI registry hosts, as host[:port], that are accessed via plain HTTP.")
+
+	fs.StringVar(&o.OCIHostsDir, "oci-hosts-dir", "",
+		"Path to a directory of containerd registry host configurations, e.g. /etc/containerd/certs.d, "+
+			"used to pull from the mirrors configured in hosts.toml files when pulling from OCI registries.")
+
+	fs.StringVar(&o.HostPathRoot, "hostpath-root", "/",
+		"The directory the node filesystem is mounted at, read by the hostpath source.")
+
+	fs.StringArrayVar(&o.HostPathAllowlist, "hostpath-allowlist", nil,
+		"An absolute path on the node that the hostpath source may read from, including its subdirectories. "+
+			"Can be specified multiple times.")
+
+	fs.StringArrayVar(&o.VolumeSourceAllowlist, "volume-source-allowlist", nil,
+		fmt.Sprintf(
+			"Allows pods to select a trusted certificates source via the %q volume attribute, "+
+				"in the form <namespace pattern>=<source pattern>, e.g. 'pci=configmap::pci/*'. "+
+				"Patterns may contain '*' wildcards. Source parameters are matched separately and must be listed "+
+				"in the source pattern, e.g. 'team-*=oci::reg.example.com/team/*?pullSecret=team/*'. "+
+				"Can be specified multiple times.",
+			csiapi.SourceKey,
+		))
+
+	fs.DurationVar(&o.RefreshInterval, "refresh-interval", manager.DefaultRefreshInterval,
+		"The interval at which trusted certificates are retrieved again for each mounted volume.")
+
+	fs.Float64Var(&o.RefreshJitter, "refresh-jitter", manager.DefaultRefreshJitter,
+		"The maximum factor of the refresh interval randomly added to each refresh interval. 0 disables the jitter.")
 }
@@ -15,3 +15,4 @@ include $(INCLUDE_DIR)goreleaser.mk
 include $(INCLUDE_DIR)docker.mk
 include $(INCLUDE_DIR)tag.mk
 include $(INCLUDE_DIR)upx.mk
+include $(INCLUDE_DIR)mozilla.mk
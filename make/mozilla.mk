@@ -0,0 +1,14 @@
+# Copyright 2022 D2iQ, Inc. All rights reserved.
+# SPDX-License-Identifier: Apache-2.0
+
+MOZILLA_CERTDATA_DIR := $(REPO_ROOT)/pkg/source/mozilla
+
+.PHONY: update-mozilla-certdata
+update-mozilla-certdata: ## Embeds a snapshot of the Mozilla root store for the NSS release specified via NSS_VERSION
+ifndef NSS_VERSION
+	$(error Please specify the NSS release to embed via NSS_VERSION env var or make variable, e.g. NSS_VERSION=3.89)
+endif
+	$(info $(M) embedding Mozilla root store from NSS $(NSS_VERSION))
+	mkdir -p $(MOZILLA_CERTDATA_DIR)/$(NSS_VERSION)
+	curl -fsSL -o $(MOZILLA_CERTDATA_DIR)/$(NSS_VERSION)/certdata.txt \
+	  https://hg.mozilla.org/projects/nss/raw-file/NSS_$(subst .,_,$(NSS_VERSION))_RTM/lib/ckfw/builtins/certdata.txt
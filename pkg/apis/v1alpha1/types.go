@@ -6,6 +6,24 @@ package v1alpha1
 const (
 	DriverName = "trusted-ca.csi.labs.d2iq.com"
 	FSGroupKey = DriverName + "/fs-group"
+	// SourceKey is the volume attribute used to select the source of the
+	// trusted certificates for a single volume, e.g. `configmap::pci/ca`.
+	SourceKey = DriverName + "/source"
+	// LayoutKey is the volume attribute used to select the layout profile of
+	// the files generated in a volume, e.g. `rhel`.
+	LayoutKey = DriverName + "/layout"
+	// BundleFileKey is the volume attribute setting the name of the CA bundle
+	// for the `custom` layout profile.
+	BundleFileKey = DriverName + "/bundle-file"
+	// BundleAliasesKey is the volume attribute setting the comma separated
+	// names symlinked to the CA bundle for the `custom` layout profile.
+	BundleAliasesKey = DriverName + "/bundle-aliases"
+	// HashedLinksKey is the volume attribute enabling the subject hash
+	// symlinks for the `custom` layout profile.
+	HashedLinksKey = DriverName + "/hashed-links"
+	// JavaTruststoresKey is the volume attribute enabling the Java
+	// truststores for the `custom` layout profile.
+	JavaTruststoresKey = DriverName + "/java-truststores"
 )
 
 const (
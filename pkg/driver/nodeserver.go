@@ -5,6 +5,7 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -16,6 +17,7 @@ import (
 
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/manager"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/source"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/storage"
 )
 
@@ -70,7 +72,7 @@ func (ns *nodeServer) NodePublishVolume(
 
 	if !ns.manager.IsVolumeReady(req.GetVolumeId()) {
 		if _, err := ns.manager.ManageVolumeImmediate(ctx, req.GetVolumeId()); err != nil {
-			return nil, err
+			return nil, publishError(err)
 		}
 		log.Info("Volume registered for management")
 	}
@@ -129,6 +131,16 @@ func (ns *nodeServer) NodeUnstageVolume(
 	return nil, status.Error(codes.Unimplemented, "NodeUnstageVolume not implemented")
 }
 
+// publishError returns the error of retrieving the certificates of a volume. Tarballs rejected by a source are
+// returned as InvalidArgument naming the offending entry, so that the pod's events show why it cannot start.
+func publishError(err error) error {
+	var tarballErr *source.TarballError
+	if errors.As(err, &tarballErr) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return err
+}
+
 func (ns *nodeServer) NodeUnpublishVolume(
 	ctx context.Context,
 	request *csi.NodeUnpublishVolumeRequest,
@@ -0,0 +1,85 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/go-logr/logr/testr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/manager"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/source"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/storage"
+)
+
+func TestNodeServer_NodePublishVolume_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+		wantMsg  string
+	}{{
+		name: "unsafe tarball entry",
+		err: fmt.Errorf("failed to read bundle: %w", &source.TarballError{
+			Entry:  "../../etc/passwd",
+			Reason: "path elements must not start with '..'",
+		}),
+		wantCode: codes.InvalidArgument,
+		wantMsg:  `entry "../../etc/passwd"`,
+	}, {
+		name:     "unavailable source",
+		err:      errors.New("failed to download bundle"),
+		wantCode: codes.Unknown,
+		wantMsg:  "failed to download bundle",
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			log := testr.New(t)
+			store := storage.NewMemoryFS()
+			mngr, err := manager.NewManager(manager.Options{
+				MetadataReader: store,
+				MetadataWriter: store,
+				Log:            &log,
+				NodeID:         "test-node-id",
+				GetCertificates: func(context.Context, metadata.Metadata) (map[string][]byte, error) {
+					return nil, tt.err
+				},
+				WriteCertificates: func(metadata.Metadata, map[string][]byte) error { return nil },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mngr.Stop()
+
+			ns := &nodeServer{
+				nodeID:  "test-node-id",
+				manager: mngr,
+				store:   store,
+				mounter: mount.NewFakeMounter(nil),
+				log:     log,
+			}
+			_, err = ns.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+				VolumeId:      "vol-id",
+				TargetPath:    t.TempDir(),
+				Readonly:      true,
+				VolumeContext: map[string]string{"csi.storage.k8s.io/ephemeral": "true"},
+			})
+
+			st, _ := status.FromError(err)
+			if st.Code() != tt.wantCode || !strings.Contains(st.Message(), tt.wantMsg) {
+				t.Errorf("expected code %v with a message containing %q but got %v", tt.wantCode, tt.wantMsg, err)
+			}
+		})
+	}
+}
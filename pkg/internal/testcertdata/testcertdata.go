@@ -0,0 +1,63 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package testcertdata provides helpers to write NSS certdata.txt files for use in tests.
+package testcertdata
+
+import (
+	"crypto/sha1" //nolint:gosec // Required to match NSS trust objects to certificates.
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Objects returns the certificate and trust objects for cert in the certdata.txt format.
+func Objects(cert *x509.Certificate, label, serverAuthTrust, emailTrust string) string {
+	return objects(cert, label, serverAuthTrust, emailTrust, "CK_BBOOL CK_FALSE\n")
+}
+
+// ObjectsDistrustedAfter returns the certificate and trust objects for cert in the certdata.txt format, with server
+// certificates issued by cert after serverDistrustAfter distrusted.
+func ObjectsDistrustedAfter(
+	cert *x509.Certificate,
+	label, serverAuthTrust, emailTrust string,
+	serverDistrustAfter time.Time,
+) string {
+	distrustAfter := "MULTILINE_OCTAL\n" + Octal([]byte(serverDistrustAfter.UTC().Format("060102150405Z"))) + "END\n"
+	return objects(cert, label, serverAuthTrust, emailTrust, distrustAfter)
+}
+
+func objects(cert *x509.Certificate, label, serverAuthTrust, emailTrust, serverDistrustAfter string) string {
+	fingerprint := sha1.Sum(cert.Raw) //nolint:gosec // Required to match NSS trust objects to certificates.
+	return fmt.Sprintf(`
+# Certificate "%[1]s"
+CKA_CLASS CK_OBJECT_CLASS CKO_CERTIFICATE
+CKA_TOKEN CK_BBOOL CK_TRUE
+CKA_LABEL UTF8 "%[1]s"
+CKA_CERTIFICATE_TYPE CK_CERTIFICATE_TYPE CKC_X_509
+CKA_VALUE MULTILINE_OCTAL
+%[2]sEND
+CKA_NSS_SERVER_DISTRUST_AFTER %[6]s
+# Trust for "%[1]s"
+CKA_CLASS CK_OBJECT_CLASS CKO_NSS_TRUST
+CKA_TOKEN CK_BBOOL CK_TRUE
+CKA_LABEL UTF8 "%[1]s"
+CKA_CERT_SHA1_HASH MULTILINE_OCTAL
+%[3]sEND
+CKA_TRUST_SERVER_AUTH CK_TRUST %[4]s
+CKA_TRUST_EMAIL_PROTECTION CK_TRUST %[5]s
+`, label, Octal(cert.Raw), Octal(fingerprint[:]), serverAuthTrust, emailTrust, serverDistrustAfter)
+}
+
+// Octal encodes data as lines of 16 octal escaped bytes.
+func Octal(data []byte) string {
+	var b strings.Builder
+	for i, c := range data {
+		fmt.Fprintf(&b, "\\%03o", c)
+		if i%16 == 15 || i == len(data)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,327 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package testregistry provides an in-memory OCI registry for use in tests.
+package testregistry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/handlers"
+	_ "github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// Registry is an in-memory OCI registry served over TLS.
+type Registry struct {
+	// Host is the host:port of the registry.
+	Host string
+	// CAFile is the path to a PEM file containing the CA certificate of the registry.
+	CAFile string
+	// Client is an HTTP client trusting the registry's CA certificate.
+	Client *http.Client
+
+	server *httptest.Server
+
+	mu sync.Mutex
+	// hold, if not nil, holds blob downloads until it is closed, after closing held.
+	hold, held chan struct{}
+	heldOnce   *sync.Once
+	// status, if not zero, is the status all requests are answered with.
+	status int
+}
+
+// New starts a new registry, which is stopped when the test completes.
+func New(t *testing.T) *Registry {
+	t.Helper()
+
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	cfg := &configuration.Configuration{}
+	cfg.Storage = configuration.Storage{"inmemory": configuration.Parameters{}}
+	cfg.Log.AccessLog.Disabled = true
+	reg := &Registry{}
+	app := handlers.NewApp(context.Background(), cfg)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if status := reg.responseStatus(); status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		if req.Method == http.MethodGet && strings.Contains(req.URL.Path, "/blobs/") {
+			reg.waitForBlobs()
+		}
+		app.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reg.Host = strings.TrimPrefix(server.URL, "https://")
+	reg.CAFile = caFile
+	reg.Client = server.Client()
+	reg.server = server
+	return reg
+}
+
+// HoldBlobs holds blob downloads until release is called, e.g. to test pulls that are in progress. held is closed once
+// the first download is held. Downloads are released when the test completes at the latest.
+func (r *Registry) HoldBlobs(t *testing.T) (held <-chan struct{}, release func()) {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hold := make(chan struct{})
+	r.hold, r.held, r.heldOnce = hold, make(chan struct{}), &sync.Once{}
+
+	var releaseOnce sync.Once
+	release = func() { releaseOnce.Do(func() { close(hold) }) }
+	// Registered after the server's cleanup, so that held downloads are released before the server is closed.
+	t.Cleanup(release)
+	return r.held, release
+}
+
+func (r *Registry) waitForBlobs() {
+	r.mu.Lock()
+	hold, held, heldOnce := r.hold, r.held, r.heldOnce
+	r.mu.Unlock()
+	if hold == nil {
+		return
+	}
+	heldOnce.Do(func() { close(held) })
+	<-hold
+}
+
+// RespondWith answers all requests with the HTTP status instead of serving them, e.g. to test behaviour when the
+// registry fails or rejects requests. A status of zero serves requests again.
+func (r *Registry) RespondWith(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *Registry) responseStatus() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Close stops the registry before the test completes, e.g. to test behaviour when the registry is unreachable.
+func (r *Registry) Close() {
+	r.server.Close()
+}
+
+// PushBlob pushes the data as a blob to the repository.
+func (r *Registry) PushBlob(t *testing.T, repo, mediaType string, data []byte) ocispecv1.Descriptor {
+	t.Helper()
+
+	resp, err := r.Client.Post(fmt.Sprintf("https://%s/v2/%s/blobs/uploads/", r.Host, repo), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status starting blob upload: %s", resp.Status)
+	}
+
+	desc := ocispecv1.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := location.Query()
+	q.Set("digest", desc.Digest.String())
+	location.RawQuery = q.Encode()
+
+	r.put(t, location.String(), "application/octet-stream", data)
+
+	return desc
+}
+
+// PushManifest pushes the manifest to the repository, tagging it with the tag if not empty.
+func (r *Registry) PushManifest(t *testing.T, repo, tag, mediaType string, manifest []byte) ocispecv1.Descriptor {
+	t.Helper()
+
+	desc := ocispecv1.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(manifest),
+		Size:      int64(len(manifest)),
+	}
+	ref := tag
+	if ref == "" {
+		ref = desc.Digest.String()
+	}
+	r.put(t, fmt.Sprintf("https://%s/v2/%s/manifests/%s", r.Host, repo, ref), mediaType, manifest)
+
+	return desc
+}
+
+// PushArtifact pushes an image manifest with the given layers to the repository.
+func (r *Registry) PushArtifact(
+	t *testing.T,
+	repo, tag string,
+	annotations map[string]string,
+	layers ...ocispecv1.Descriptor,
+) ocispecv1.Descriptor {
+	t.Helper()
+
+	config := r.PushBlob(t, repo, ocispecv1.MediaTypeImageConfig, []byte("{}"))
+	manifest, err := json.Marshal(ocispecv1.Manifest{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispecv1.MediaTypeImageManifest,
+		Config:      config,
+		Layers:      layers,
+		Annotations: annotations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.PushManifest(t, repo, tag, ocispecv1.MediaTypeImageManifest, manifest)
+}
+
+// PushIndex pushes an image index listing the manifests to the repository, tagging it with the tag if not empty.
+func (r *Registry) PushIndex(t *testing.T, repo, tag string, manifests ...ocispecv1.Descriptor) ocispecv1.Descriptor {
+	t.Helper()
+
+	index, err := json.Marshal(ocispecv1.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispecv1.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.PushManifest(t, repo, tag, ocispecv1.MediaTypeImageIndex, index)
+}
+
+func (r *Registry) put(t *testing.T, url, contentType string, data []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("unexpected status pushing to %s: %s: %s", url, resp.Status, body)
+	}
+}
+
+// PushCosignSignature pushes a cosign signature of the manifest described by desc, signed with the key.
+func (r *Registry) PushCosignSignature(
+	t *testing.T,
+	repo string,
+	desc ocispecv1.Descriptor,
+	key *ecdsa.PrivateKey,
+) {
+	t.Helper()
+
+	payload := []byte(fmt.Sprintf(
+		`{"critical":{"identity":{"docker-reference":"%s/%s"},"image":{"docker-manifest-digest":"%s"},`+
+			`"type":"cosign container image signature"},"optional":null}`,
+		r.Host, repo, desc.Digest,
+	))
+	digest := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigLayer := r.PushBlob(t, repo, "application/vnd.dev.cosign.simplesigning.v1+json", payload)
+	sigLayer.Annotations = map[string]string{
+		"dev.cosignproject.cosign/signature": base64.StdEncoding.EncodeToString(sig),
+	}
+	r.PushArtifact(
+		t, repo, fmt.Sprintf("%s-%s.sig", desc.Digest.Algorithm(), desc.Digest.Encoded()), nil, sigLayer,
+	)
+}
+
+// Tarball returns a tarball containing the files.
+func Tarball(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(files[name])),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// ReadTarball returns the contents of all files in the tarball.
+func ReadTarball(t *testing.T, r *tar.Reader) map[string][]byte {
+	t.Helper()
+
+	files := map[string][]byte{}
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = data
+	}
+}
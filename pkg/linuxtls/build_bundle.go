@@ -4,64 +4,113 @@
 package linuxtls
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
-	"io"
-	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/pki"
 )
 
-func CreateCABundle(dir string) (sets.Set[string], error) {
-	bundleFilepath := filepath.Join(dir, "ca-certificates.crt")
-	klog.V(4).Infof("Creating CA bundle: %q", bundleFilepath)
-	bundleFile, err := os.Create(bundleFilepath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create bundle file: %w", err)
-	}
-	defer bundleFile.Close()
+// bundleCertificate is a certificate included in the CA bundle.
+type bundleCertificate struct {
+	cert        *x509.Certificate
+	fingerprint [sha256.Size]byte
+	// source is the name of the first file the certificate was read from.
+	source string
+}
+
+// CreateCABundle returns a directory func that writes every unique certificate in the files in the directory to the
+// CA bundle configured in the layout. Files that do not contain certificates are skipped, and certificates are
+// deduplicated by SHA-256 fingerprint. Certificates are ordered by subject and fingerprint, so the bundle does not
+// depend on the names of the files, and each is preceded by a comment describing it. A file with the name of the bundle
+// is replaced by the bundle, which includes its certificates.
+func CreateCABundle(layout Layout) func(dir string) (sets.Set[string], error) {
+	return func(dir string) (sets.Set[string], error) {
+		bundleFilepath := filepath.Join(dir, layout.BundleFile)
 
-	err = fs.WalkDir(os.DirFS("/"), dir, func(path string, d fs.DirEntry, err error) error {
+		// The certificates of a source file with the name of the bundle are included in the bundle replacing it.
+		certs, err := readDirCertificates(dir)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if err := replaceSourceFile(dir, layout.BundleFile, "CA bundle"); err != nil {
+			return nil, err
 		}
+		sort.Slice(certs, func(i, j int) bool {
+			if si, sj := certs[i].cert.Subject.String(), certs[j].cert.Subject.String(); si != sj {
+				return si < sj
+			}
+			return bytes.Compare(certs[i].fingerprint[:], certs[j].fingerprint[:]) < 0
+		})
 
-		if d.IsDir() {
-			if path == dir {
-				return nil
+		var buf bytes.Buffer
+		for i, c := range certs {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			fmt.Fprintf(&buf, "# Subject: %s\n", c.cert.Subject)
+			fmt.Fprintf(&buf, "# Issuer: %s\n", c.cert.Issuer)
+			fmt.Fprintf(&buf, "# Expires: %s\n", c.cert.NotAfter.UTC().Format(time.RFC3339))
+			fmt.Fprintf(&buf, "# Source: %s\n", c.source)
+			if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}); err != nil {
+				return nil, fmt.Errorf("failed to encode certificate %q: %w", c.cert.Subject, err)
 			}
-			return fs.SkipDir
 		}
 
-		if filepath.Base(path) == "ca-certificates.crt" {
-			return nil
+		klog.V(4).Infof("Creating CA bundle with %d certificates: %q", len(certs), bundleFilepath)
+		if err := os.MkdirAll(filepath.Dir(bundleFilepath), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for bundle file: %w", err)
 		}
-
-		f, innerErr := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("failed to read %q: %w", path, innerErr)
+		if err := os.WriteFile(bundleFilepath, buf.Bytes(), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write bundle file: %w", err)
 		}
-		defer f.Close()
 
-		_, innerErr = io.Copy(bundleFile, f)
+		return sets.New(layout.BundleFile), nil
+	}
+}
+
+// readDirCertificates reads the unique certificates from the regular files in the directory and its subdirectories, in
+// path order.
+func readDirCertificates(dir string) ([]bundleCertificate, error) {
+	names, err := regularFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		certs []bundleCertificate
+		seen  = sets.New[[sha256.Size]byte]()
+	)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
 		if err != nil {
-			return fmt.Errorf("failed to copy %q into certificate bundle: %w", path, innerErr)
+			return nil, fmt.Errorf("failed to read %q: %w", name, err)
 		}
-
-		_, innerErr = fmt.Fprint(bundleFile, "\n\n")
+		fileCerts, err := pki.ParseCertificates(data)
 		if err != nil {
-			return fmt.Errorf("failed to copy %q into certificate bundle: %w", path, innerErr)
+			klog.V(4).Infof("Skipping %q in CA bundle: %v", name, err)
+			continue
 		}
 
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to walk certificate files to create bundle: %w", err)
+		for _, cert := range fileCerts {
+			fingerprint := sha256.Sum256(cert.Raw)
+			if seen.Has(fingerprint) {
+				klog.V(4).Infof("Skipping duplicate certificate %q in %q", cert.Subject, name)
+				continue
+			}
+			seen.Insert(fingerprint)
+			certs = append(certs, bundleCertificate{cert: cert, fingerprint: fingerprint, source: filepath.ToSlash(name)})
+		}
 	}
 
-	klog.V(4).Infof("Created CA bundle: %q", bundleFilepath)
-
-	return sets.New("ca-certificates.crt"), nil
+	return certs, nil
 }
@@ -0,0 +1,80 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package linuxtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateCABundle(t *testing.T) {
+	dir := t.TempDir()
+	caA := newTestCA(t, "a")
+	caB := newTestCA(t, "b")
+	caC, _ := pem.Decode(newTestCA(t, "c"))
+	files := map[string][]byte{
+		"1.pem":            bytes.Join([][]byte{caB, caA}, nil),
+		"2.pem":            caA,
+		"3.der":            caC.Bytes,
+		"nested/dir/4.pem": newTestCA(t, "d"),
+		"README.md":        []byte("These are our CA certificates."),
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := CreateCABundle(Layouts[LayoutDebian])(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := os.ReadFile(filepath.Join(dir, DefaultBundleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		subjects []string
+		block    *pem.Block
+		rest     = bundle
+	)
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects = append(subjects, cert.Subject.String())
+	}
+	if want := []string{"CN=a", "CN=b", "CN=c", "CN=d"}; !reflect.DeepEqual(subjects, want) {
+		t.Errorf("expected certificates %v in bundle but got %v", want, subjects)
+	}
+
+	for _, header := range []string{
+		"# Subject: CN=a\n# Issuer: CN=a\n# Expires: ",
+		"# Source: 1.pem\n-----BEGIN CERTIFICATE-----",
+		"# Source: 3.der\n-----BEGIN CERTIFICATE-----",
+		"# Source: nested/dir/4.pem\n-----BEGIN CERTIFICATE-----",
+	} {
+		if !strings.Contains(string(bundle), header) {
+			t.Errorf("expected bundle to contain %q but got:\n%s", header, bundle)
+		}
+	}
+	if strings.Contains(string(bundle), "# Source: 2.pem") || strings.Contains(string(bundle), "README.md") {
+		t.Errorf("expected duplicate certificates and non-certificate files to be skipped but got:\n%s", bundle)
+	}
+}
@@ -4,14 +4,89 @@
 package linuxtls
 
 import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
 
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
 )
 
-func DirFuncsForVolume(meta metadata.Metadata) []func(dir string) (sets.Set[string], error) {
-	return []func(dir string) (sets.Set[string], error){
-		CreateCABundle,
-		OpenSSLRehash,
+// Options configure the files generated in volumes from the trusted CA certificates.
+type Options struct {
+	// JavaTruststorePassword is the password of the Java truststores. Defaults to DefaultJavaTruststorePassword.
+	JavaTruststorePassword string
+
+	// JavaJKSTruststore enables writing a legacy JKS truststore in addition to the PKCS#12 truststore.
+	JavaJKSTruststore bool
+
+	// LegacySubjectHash enables creating symlinks named after the subject hash used by OpenSSL before 1.0.0 in
+	// addition to the current subject hash, for old clients.
+	LegacySubjectHash bool
+}
+
+// DirFuncsForVolume returns the directory funcs generating the files of the layout selected for the volume.
+func DirFuncsForVolume(meta metadata.Metadata, opts Options) ([]func(dir string) (sets.Set[string], error), error) {
+	layout, err := LayoutForVolume(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	dirFuncs := []func(dir string) (sets.Set[string], error){
+		CreateCABundle(layout),
+		BundleAliases(layout),
+	}
+	if layout.HashedLinks {
+		dirFuncs = append(dirFuncs, Rehash(opts))
+	}
+	if layout.JavaTruststores {
+		dirFuncs = append(dirFuncs, JavaTruststores(opts, layout))
+	}
+	return dirFuncs, nil
+}
+
+// regularFiles returns the paths, relative to dir, of the regular files in dir and its subdirectories, in lexical
+// order. Symlinks are not followed.
+func regularFiles(dir string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %q: %w", dir, err)
 	}
+	return names, nil
+}
+
+// replaceSourceFile removes the file written by a source at the path, relative to dir, so that the generated file
+// named by kind can replace it. Directories written by sources are not replaced.
+func replaceSourceFile(dir, name, kind string) error {
+	path := filepath.Join(dir, name)
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s %q conflicts with a directory of the same name", kind, name)
+	}
+
+	klog.Infof("Replacing source file %q with the %s of the same name", name, kind)
+	return os.Remove(path)
 }
@@ -0,0 +1,128 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package linuxtls
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// DefaultJavaTruststorePassword is the well-known default password of Java truststores.
+	DefaultJavaTruststorePassword = "changeit"
+
+	// PKCS12TruststoreFile is the name of the PKCS#12 truststore written to volumes.
+	PKCS12TruststoreFile = "truststore.p12"
+	// JKSTruststoreFile is the name of the legacy JKS truststore written to volumes if enabled.
+	JKSTruststoreFile = "truststore.jks"
+)
+
+// JavaTruststores returns a directory func that writes Java truststores containing every certificate in the CA
+// bundle created by CreateCABundle for the layout.
+func JavaTruststores(opts Options, layout Layout) func(dir string) (sets.Set[string], error) {
+	return func(dir string) (sets.Set[string], error) {
+		password := opts.JavaTruststorePassword
+		if password == "" {
+			password = DefaultJavaTruststorePassword
+		}
+
+		certs, err := readBundleCertificates(filepath.Join(dir, layout.BundleFile))
+		if err != nil {
+			return nil, err
+		}
+
+		newFiles := sets.New[string]()
+
+		klog.V(4).Infof("Creating PKCS#12 truststore: %q", filepath.Join(dir, PKCS12TruststoreFile))
+		entries := make([]pkcs12.TrustStoreEntry, 0, len(certs))
+		for _, cert := range certs {
+			entries = append(entries, pkcs12.TrustStoreEntry{Cert: cert, FriendlyName: truststoreAlias(cert)})
+		}
+		pfxData, err := pkcs12.EncodeTrustStoreEntries(rand.Reader, entries, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode PKCS#12 truststore: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, PKCS12TruststoreFile), pfxData, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write PKCS#12 truststore: %w", err)
+		}
+		newFiles.Insert(PKCS12TruststoreFile)
+
+		if !opts.JavaJKSTruststore {
+			return newFiles, nil
+		}
+
+		klog.V(4).Infof("Creating JKS truststore: %q", filepath.Join(dir, JKSTruststoreFile))
+		ks := keystore.New()
+		now := time.Now()
+		for _, cert := range certs {
+			err := ks.SetTrustedCertificateEntry(truststoreAlias(cert), keystore.TrustedCertificateEntry{
+				CreationTime: now,
+				Certificate:  keystore.Certificate{Type: "X509", Content: cert.Raw},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to add certificate %q to JKS truststore: %w", cert.Subject, err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := ks.Store(&buf, []byte(password)); err != nil {
+			return nil, fmt.Errorf("failed to encode JKS truststore: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, JKSTruststoreFile), buf.Bytes(), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write JKS truststore: %w", err)
+		}
+		newFiles.Insert(JKSTruststoreFile)
+
+		return newFiles, nil
+	}
+}
+
+// readBundleCertificates reads the unique certificates from the PEM encoded CA bundle.
+func readBundleCertificates(bundlePath string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	var (
+		certs []*x509.Certificate
+		seen  = sets.New[string]()
+		block *pem.Block
+	)
+	for {
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" || seen.Has(string(block.Bytes)) {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in CA bundle: %w", err)
+		}
+		seen.Insert(string(block.Bytes))
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// truststoreAlias returns a unique alias for the certificate, as certificates with the same subject would otherwise
+// overwrite each other.
+func truststoreAlias(cert *x509.Certificate) string {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return fmt.Sprintf("%s [%x]", cert.Subject.String(), fingerprint[:8])
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package linuxtls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestJavaTruststores(t *testing.T) {
+	dir := t.TempDir()
+	caA := newTestCA(t, "a")
+	caB := newTestCA(t, "b")
+	// caA is included twice, e.g. from two source files, and must only be added to the truststores once.
+	bundle := bytes.Join([][]byte{caA, caB, caA}, []byte("\n\n"))
+	if err := os.WriteFile(filepath.Join(dir, DefaultBundleFile), bundle, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{JavaTruststorePassword: "secret", JavaJKSTruststore: true}
+	newFiles, err := JavaTruststores(opts, Layouts[LayoutDebian])(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sets.New(PKCS12TruststoreFile, JKSTruststoreFile); !newFiles.Equal(want) {
+		t.Errorf("expected new files %v but got %v", sets.List(want), sets.List(newFiles))
+	}
+
+	pfxData, err := os.ReadFile(filepath.Join(dir, PKCS12TruststoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := pkcs12.DecodeTrustStore(pfxData, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 {
+		t.Errorf("expected 2 certificates in PKCS#12 truststore but got %d", len(certs))
+	}
+
+	jksData, err := os.ReadFile(filepath.Join(dir, JKSTruststoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := keystore.New()
+	if err := ks.Load(bytes.NewReader(jksData), []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if aliases := ks.Aliases(); len(aliases) != 2 {
+		t.Errorf("expected 2 certificates in JKS truststore but got %v", aliases)
+	}
+}
+
+func TestJavaTruststores_DefaultPassword(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, DefaultBundleFile), newTestCA(t, "a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	newFiles, err := JavaTruststores(Options{}, Layouts[LayoutDebian])(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newFiles.Has(JKSTruststoreFile) {
+		t.Errorf("expected no JKS truststore unless enabled")
+	}
+
+	pfxData, err := os.ReadFile(filepath.Join(dir, PKCS12TruststoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pkcs12.DecodeTrustStore(pfxData, DefaultJavaTruststorePassword); err != nil {
+		t.Errorf("expected truststore to be readable with the default password: %v", err)
+	}
+}
+
+func newTestCA(t *testing.T, cn string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
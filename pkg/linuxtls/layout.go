@@ -0,0 +1,181 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package linuxtls
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	csiapi "github.com/d2iq-labs/csi-driver-trusted-ca/pkg/apis/v1alpha1"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+const (
+	// LayoutDebian is the default layout, for Debian and Ubuntu based images mounting the volume at /etc/ssl/certs.
+	LayoutDebian = "debian"
+	// LayoutRHEL is the layout for RHEL, UBI and Fedora based images mounting the volume at
+	// /etc/pki/ca-trust/extracted/pem.
+	LayoutRHEL = "rhel"
+	// LayoutAlpine is the layout for Alpine based images mounting the volume at /etc/ssl/certs.
+	LayoutAlpine = "alpine"
+	// LayoutMinimal is the layout for applications that only read a single `ca.pem` bundle.
+	LayoutMinimal = "minimal"
+	// LayoutCustom is the layout configured via the BundleFileKey, BundleAliasesKey, HashedLinksKey and
+	// JavaTruststoresKey volume attributes.
+	LayoutCustom = "custom"
+)
+
+// DefaultBundleFile is the name of the CA bundle in the debian layout.
+const DefaultBundleFile = "ca-certificates.crt"
+
+// Layout defines the files generated in a volume from the trusted CA certificates.
+type Layout struct {
+	// BundleFile is the path, relative to the volume, of the CA bundle containing every certificate.
+	BundleFile string
+
+	// BundleAliases are the paths, relative to the volume, of symlinks to BundleFile.
+	BundleAliases []string
+
+	// HashedLinks enables creating the `<hash>.<n>` subject hash symlinks to each certificate file.
+	HashedLinks bool
+
+	// JavaTruststores enables writing the Java truststores.
+	JavaTruststores bool
+}
+
+// Layouts are the predefined layout profiles, keyed by name.
+var Layouts = map[string]Layout{
+	LayoutDebian: {
+		BundleFile:      DefaultBundleFile,
+		HashedLinks:     true,
+		JavaTruststores: true,
+	},
+	LayoutRHEL: {
+		BundleFile:      "tls-ca-bundle.pem",
+		BundleAliases:   []string{"ca-bundle.crt"},
+		JavaTruststores: true,
+	},
+	LayoutAlpine: {
+		BundleFile:      DefaultBundleFile,
+		BundleAliases:   []string{"cert.pem"},
+		HashedLinks:     true,
+		JavaTruststores: true,
+	},
+	LayoutMinimal: {
+		BundleFile: "ca.pem",
+	},
+}
+
+// LayoutForVolume returns the layout selected via the LayoutKey volume attribute, defaulting to the debian layout.
+func LayoutForVolume(meta metadata.Metadata) (Layout, error) {
+	name, ok := meta.VolumeContext[csiapi.LayoutKey]
+	if !ok {
+		return Layouts[LayoutDebian], nil
+	}
+
+	if name == LayoutCustom {
+		return customLayout(meta.VolumeContext)
+	}
+
+	layout, ok := Layouts[name]
+	if !ok {
+		return Layout{}, fmt.Errorf("invalid %q volume attribute %q, must be one of %q",
+			csiapi.LayoutKey, name, LayoutNames())
+	}
+	return layout, nil
+}
+
+// LayoutNames returns the sorted names of the layout profiles that can be selected.
+func LayoutNames() []string {
+	names := append(sets.List(sets.KeySet(Layouts)), LayoutCustom)
+	sort.Strings(names)
+	return names
+}
+
+func customLayout(attrs map[string]string) (Layout, error) {
+	layout := Layout{
+		BundleFile:      DefaultBundleFile,
+		HashedLinks:     true,
+		JavaTruststores: true,
+	}
+
+	if bundleFile, ok := attrs[csiapi.BundleFileKey]; ok {
+		layout.BundleFile = bundleFile
+	}
+	if err := validateLayoutPath(csiapi.BundleFileKey, layout.BundleFile); err != nil {
+		return Layout{}, err
+	}
+
+	if aliases := attrs[csiapi.BundleAliasesKey]; aliases != "" {
+		for _, alias := range strings.Split(aliases, ",") {
+			alias = strings.TrimSpace(alias)
+			if err := validateLayoutPath(csiapi.BundleAliasesKey, alias); err != nil {
+				return Layout{}, err
+			}
+			layout.BundleAliases = append(layout.BundleAliases, alias)
+		}
+	}
+
+	for key, field := range map[string]*bool{
+		csiapi.HashedLinksKey:     &layout.HashedLinks,
+		csiapi.JavaTruststoresKey: &layout.JavaTruststores,
+	} {
+		value, ok := attrs[key]
+		if !ok {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return Layout{}, fmt.Errorf("invalid %q volume attribute %q, must be a boolean", key, value)
+		}
+		*field = enabled
+	}
+
+	return layout, nil
+}
+
+// validateLayoutPath returns an error if the path is not a clean relative path within the volume.
+func validateLayoutPath(key, path string) error {
+	// Paths starting with ".." are reserved for the atomic writer.
+	if path == "" || filepath.IsAbs(path) || filepath.Clean(path) != path || strings.HasPrefix(path, "..") {
+		return fmt.Errorf("invalid %q volume attribute %q, must be a clean relative path within the volume", key, path)
+	}
+	return nil
+}
+
+// BundleAliases returns a directory func that creates the symlinks to the CA bundle configured in the layout. Files with
+// the name of an alias are replaced by the alias.
+func BundleAliases(layout Layout) func(dir string) (sets.Set[string], error) {
+	return func(dir string) (sets.Set[string], error) {
+		newFiles := sets.New[string]()
+		for _, alias := range layout.BundleAliases {
+			aliasPath := filepath.Join(dir, alias)
+			// The certificates of a source file with the name of the alias have been included in the bundle.
+			if err := replaceSourceFile(dir, alias, "CA bundle alias"); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(filepath.Dir(aliasPath), 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create directory for CA bundle alias %q: %w", alias, err)
+			}
+
+			target, err := filepath.Rel(filepath.Dir(alias), layout.BundleFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create CA bundle alias %q: %w", alias, err)
+			}
+			klog.V(4).Infof("Linking CA bundle alias %q to %q", alias, target)
+			if err := os.Symlink(target, aliasPath); err != nil {
+				return nil, fmt.Errorf("failed to create CA bundle alias %q: %w", alias, err)
+			}
+			newFiles.Insert(alias)
+		}
+		return newFiles, nil
+	}
+}
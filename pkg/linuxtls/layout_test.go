@@ -0,0 +1,185 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package linuxtls
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	csiapi "github.com/d2iq-labs/csi-driver-trusted-ca/pkg/apis/v1alpha1"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestLayoutForVolume(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   map[string]string
+		want    Layout
+		wantErr bool
+	}{{
+		name: "default",
+		want: Layouts[LayoutDebian],
+	}, {
+		name:  "predefined",
+		attrs: map[string]string{csiapi.LayoutKey: LayoutRHEL},
+		want:  Layouts[LayoutRHEL],
+	}, {
+		name:    "unknown",
+		attrs:   map[string]string{csiapi.LayoutKey: "windows"},
+		wantErr: true,
+	}, {
+		name: "custom",
+		attrs: map[string]string{
+			csiapi.LayoutKey:          LayoutCustom,
+			csiapi.BundleFileKey:      "bundle/ca.pem",
+			csiapi.BundleAliasesKey:   "ca.crt, legacy/ca-bundle.crt",
+			csiapi.HashedLinksKey:     "false",
+			csiapi.JavaTruststoresKey: "false",
+		},
+		want: Layout{
+			BundleFile:    "bundle/ca.pem",
+			BundleAliases: []string{"ca.crt", "legacy/ca-bundle.crt"},
+		},
+	}, {
+		name:  "custom defaults",
+		attrs: map[string]string{csiapi.LayoutKey: LayoutCustom},
+		want:  Layouts[LayoutDebian],
+	}, {
+		name:    "custom path traversal",
+		attrs:   map[string]string{csiapi.LayoutKey: LayoutCustom, csiapi.BundleAliasesKey: "../ca.pem"},
+		wantErr: true,
+	}, {
+		name:    "custom absolute path",
+		attrs:   map[string]string{csiapi.LayoutKey: LayoutCustom, csiapi.BundleFileKey: "/etc/ca.pem"},
+		wantErr: true,
+	}, {
+		name:    "custom invalid boolean",
+		attrs:   map[string]string{csiapi.LayoutKey: LayoutCustom, csiapi.HashedLinksKey: "maybe"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LayoutForVolume(metadata.Metadata{VolumeContext: tt.attrs})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t but got: %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected layout %+v but got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDirFuncsForVolume(t *testing.T) {
+	tests := []struct {
+		name      string
+		layout    string
+		wantFiles sets.Set[string]
+		wantLinks map[string]string
+	}{{
+		name:      "rhel",
+		layout:    LayoutRHEL,
+		wantFiles: sets.New("tls-ca-bundle.pem", "ca-bundle.crt", PKCS12TruststoreFile),
+		wantLinks: map[string]string{"ca-bundle.crt": "tls-ca-bundle.pem"},
+	}, {
+		name:      "minimal",
+		layout:    LayoutMinimal,
+		wantFiles: sets.New("ca.pem"),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "a.crt"), newTestCA(t, "a"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			dirFuncs, err := DirFuncsForVolume(
+				metadata.Metadata{VolumeContext: map[string]string{csiapi.LayoutKey: tt.layout}},
+				Options{},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newFiles := sets.New[string]()
+			for _, f := range dirFuncs {
+				files, err := f(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				newFiles = newFiles.Union(files)
+			}
+
+			if !newFiles.Equal(tt.wantFiles) {
+				t.Errorf("expected new files %v but got %v", sets.List(tt.wantFiles), sets.List(newFiles))
+			}
+			for link, target := range tt.wantLinks {
+				got, err := os.Readlink(filepath.Join(dir, link))
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				if got != target {
+					t.Errorf("expected %q to link to %q but got %q", link, target, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDirFuncsForVolume_ReplacesSourceFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		DefaultBundleFile: newTestCA(t, "a"),
+		"b.crt":           newTestCA(t, "b"),
+		"cert.pem":        newTestCA(t, "c"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dirFuncs, err := DirFuncsForVolume(
+		metadata.Metadata{VolumeContext: map[string]string{csiapi.LayoutKey: LayoutAlpine}},
+		Options{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range dirFuncs {
+		if _, err := f(dir); err != nil {
+			t.Fatalf("expected the generated files to replace the source files but got: %v", err)
+		}
+	}
+
+	bundle, err := os.ReadFile(filepath.Join(dir, DefaultBundleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for rest := bundle; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects = append(subjects, cert.Subject.String())
+	}
+	if want := []string{"CN=a", "CN=b", "CN=c"}; !reflect.DeepEqual(subjects, want) {
+		t.Errorf("expected the certificates of the replaced files %v in the bundle but got %v", want, subjects)
+	}
+
+	if got, err := os.Readlink(filepath.Join(dir, "cert.pem")); err != nil || got != DefaultBundleFile {
+		t.Errorf("expected %q to link to %q but got %q: %v", "cert.pem", DefaultBundleFile, got, err)
+	}
+}
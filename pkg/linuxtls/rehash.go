@@ -0,0 +1,278 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package linuxtls
+
+import (
+	"bytes"
+	"crypto/md5"  //nolint:gosec // Required to compute the legacy OpenSSL subject hash.
+	"crypto/sha1" //nolint:gosec // Required to compute the OpenSSL subject hash.
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// rehashExtensions are the extensions of the files considered by `openssl rehash`.
+var rehashExtensions = sets.New(".pem", ".crt", ".cer", ".crl")
+
+// Rehash returns a directory func that creates the `<hash>.<n>` symlinks to each certificate file in the directory and
+// its subdirectories, equivalent to `openssl rehash`. The symlinks are created in the directory itself. The hash is the OpenSSL canonical subject name hash and, if enabled via
+// Options.LegacySubjectHash, additionally the legacy subject hash used by OpenSSL before 1.0.0. Like `openssl rehash`,
+// only files with a .pem, .crt, .cer or .crl extension that contain exactly one certificate are linked, and duplicate
+// certificates are only linked once.
+func Rehash(opts Options) func(dir string) (sets.Set[string], error) {
+	return func(dir string) (sets.Set[string], error) {
+		names, err := regularFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		// fingerprints holds the SHA1 fingerprints of the certificates linked for each hash, in link order.
+		fingerprints := map[uint32][][sha1.Size]byte{}
+		newFiles := sets.New[string]()
+
+		link := func(name string, hash uint32, fingerprint [sha1.Size]byte) error {
+			for _, fp := range fingerprints[hash] {
+				if fp == fingerprint {
+					klog.V(4).Infof("Skipping duplicate certificate in %q", name)
+					return nil
+				}
+			}
+			linkName := fmt.Sprintf("%08x.%d", hash, len(fingerprints[hash]))
+			if err := os.Symlink(name, filepath.Join(dir, linkName)); err != nil {
+				return fmt.Errorf("failed to link %q to %q: %w", linkName, name, err)
+			}
+			fingerprints[hash] = append(fingerprints[hash], fingerprint)
+			newFiles.Insert(linkName)
+			return nil
+		}
+
+		// The files are sorted by path, so links are numbered deterministically.
+		for _, name := range names {
+			if !rehashExtensions.Has(strings.ToLower(filepath.Ext(name))) {
+				continue
+			}
+
+			cert, err := readSingleCertificate(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			if cert == nil {
+				klog.V(4).Infof("Skipping %q, it does not contain exactly one certificate", name)
+				continue
+			}
+
+			fingerprint := sha1.Sum(cert.Raw) //nolint:gosec // Only used to detect duplicates.
+			hash, err := SubjectHash(cert)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute subject hash of %q: %w", name, err)
+			}
+			if err := link(name, hash, fingerprint); err != nil {
+				return nil, err
+			}
+			if opts.LegacySubjectHash {
+				if err := link(name, LegacySubjectHash(cert), fingerprint); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return newFiles, nil
+	}
+}
+
+// readSingleCertificate returns the certificate in the PEM file, or nil if the file does not contain exactly one
+// certificate.
+func readSingleCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	var (
+		certDER []byte
+		count   int
+		block   *pem.Block
+	)
+	for {
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE", "TRUSTED CERTIFICATE", "X509 CERTIFICATE", "X509 CRL":
+			certDER = block.Bytes
+			count++
+		}
+	}
+	if count != 1 {
+		return nil, nil
+	}
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		// CRLs and unparseable certificates are not linked.
+		return nil, nil //nolint:nilerr // Skipped like `openssl rehash` skips files it cannot read.
+	}
+	return cert, nil
+}
+
+// SubjectHash returns the OpenSSL canonical subject name hash of the certificate, as printed by
+// `openssl x509 -subject_hash`. It is the little-endian uint32 of the first 4 bytes of the SHA1 hash of the canonical
+// encoding of the subject name.
+func SubjectHash(cert *x509.Certificate) (uint32, error) {
+	canonical, err := canonicalName(cert.RawSubject)
+	if err != nil {
+		return 0, err
+	}
+	sum := sha1.Sum(canonical) //nolint:gosec // Required to compute the OpenSSL subject hash.
+	return binary.LittleEndian.Uint32(sum[:4]), nil
+}
+
+// LegacySubjectHash returns the subject name hash used by OpenSSL before 1.0.0, as printed by
+// `openssl x509 -subject_hash_old`. It is the little-endian uint32 of the first 4 bytes of the MD5 hash of the DER
+// encoded subject name.
+func LegacySubjectHash(cert *x509.Certificate) uint32 {
+	sum := md5.Sum(cert.RawSubject) //nolint:gosec // Required to compute the legacy OpenSSL subject hash.
+	return binary.LittleEndian.Uint32(sum[:4])
+}
+
+type attributeTypeAndValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// canonicalName returns the canonical encoding of the DER encoded name, as computed by OpenSSL's x509_name_canon: the
+// concatenated DER encodings of each relative distinguished name, without the outer sequence, with string values
+// converted to lowercased UTF8Strings with whitespace trimmed and collapsed.
+func canonicalName(rawName []byte) ([]byte, error) {
+	var rdns []asn1.RawValue
+	if rest, err := asn1.Unmarshal(rawName, &rdns); err != nil {
+		return nil, fmt.Errorf("invalid name: %w", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("invalid name: trailing data")
+	}
+
+	var canonical []byte
+	for _, rdn := range rdns {
+		var atvs []attributeTypeAndValue
+		if _, err := asn1.UnmarshalWithParams(rdn.FullBytes, &atvs, "set"); err != nil {
+			return nil, fmt.Errorf("invalid relative distinguished name: %w", err)
+		}
+
+		encodedATVs := make([][]byte, 0, len(atvs))
+		for _, atv := range atvs {
+			value, err := canonicalValue(atv.Value)
+			if err != nil {
+				return nil, err
+			}
+			encoded, err := asn1.Marshal(attributeTypeAndValue{Type: atv.Type, Value: value})
+			if err != nil {
+				return nil, err
+			}
+			encodedATVs = append(encodedATVs, encoded)
+		}
+		// The members of a DER encoded SET OF are sorted by their encodings.
+		sort.Slice(encodedATVs, func(i, j int) bool {
+			return bytes.Compare(encodedATVs[i], encodedATVs[j]) < 0
+		})
+
+		encodedRDN, err := asn1.Marshal(asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      bytes.Join(encodedATVs, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		canonical = append(canonical, encodedRDN...)
+	}
+
+	return canonical, nil
+}
+
+// canonicalValue returns the canonical form of the attribute value. String values are converted to UTF8Strings, with
+// leading and trailing whitespace removed, internal whitespace collapsed to a single space and ASCII characters
+// lowercased. Other values are returned unchanged.
+func canonicalValue(value asn1.RawValue) (asn1.RawValue, error) {
+	if value.Class != asn1.ClassUniversal {
+		return asn1.RawValue{FullBytes: value.FullBytes}, nil
+	}
+
+	var utf8Value []byte
+	switch value.Tag {
+	case asn1.TagUTF8String, asn1.TagPrintableString, asn1.TagIA5String, 26 /* VisibleString */ :
+		utf8Value = value.Bytes
+	case asn1.TagT61String:
+		// OpenSSL treats T61Strings as Latin-1.
+		runes := make([]rune, 0, len(value.Bytes))
+		for _, b := range value.Bytes {
+			runes = append(runes, rune(b))
+		}
+		utf8Value = []byte(string(runes))
+	case asn1.TagBMPString:
+		if len(value.Bytes)%2 != 0 {
+			return asn1.RawValue{}, fmt.Errorf("invalid BMPString length %d", len(value.Bytes))
+		}
+		units := make([]uint16, 0, len(value.Bytes)/2)
+		for i := 0; i < len(value.Bytes); i += 2 {
+			units = append(units, binary.BigEndian.Uint16(value.Bytes[i:]))
+		}
+		utf8Value = []byte(string(utf16.Decode(units)))
+	case 28: // UniversalString
+		if len(value.Bytes)%4 != 0 {
+			return asn1.RawValue{}, fmt.Errorf("invalid UniversalString length %d", len(value.Bytes))
+		}
+		for i := 0; i < len(value.Bytes); i += 4 {
+			utf8Value = utf8.AppendRune(utf8Value, rune(binary.BigEndian.Uint32(value.Bytes[i:])))
+		}
+	default:
+		return asn1.RawValue{FullBytes: value.FullBytes}, nil
+	}
+
+	return asn1.RawValue{
+		Class: asn1.ClassUniversal,
+		Tag:   asn1.TagUTF8String,
+		Bytes: canonicalString(utf8Value),
+	}, nil
+}
+
+// canonicalString trims and collapses ASCII whitespace and lowercases ASCII characters, leaving other bytes unchanged.
+func canonicalString(s []byte) []byte {
+	s = bytes.TrimFunc(s, func(r rune) bool { return r < utf8.RuneSelf && isSpace(byte(r)) })
+
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= utf8.RuneSelf:
+			out = append(out, c)
+		case isSpace(c):
+			out = append(out, ' ')
+			for i+1 < len(s) && isSpace(s[i+1]) {
+				i++
+			}
+		case c >= 'A' && c <= 'Z':
+			out = append(out, c+('a'-'A'))
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package linuxtls
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// utf8SubjectCert has the subject `C=US, O=D2iQ,  Inc., CN=  Test   Root CA ` encoded as UTF8Strings.
+const utf8SubjectCert = `-----BEGIN CERTIFICATE-----
+MIIB1DCCAXugAwIBAgIUGp8dgjVTSnzGUvaHm+IW5CnP1J4wCgYIKoZIzj0EAwIw
+PzELMAkGA1UEBhMCVVMxFDASBgNVBAoMC0QyaVEsICBJbmMuMRowGAYDVQQDDBEg
+IFRlc3QgICBSb290IENBIDAgFw0yNjEwMTcxNzE1MzRaGA8yMTI2MDkyMzE3MTUz
+NFowPzELMAkGA1UEBhMCVVMxFDASBgNVBAoMC0QyaVEsICBJbmMuMRowGAYDVQQD
+DBEgIFRlc3QgICBSb290IENBIDBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABF0D
+z7+w9HamfbLyGI8TYtArYn7IzXk4KF16L+NTqcdgtx+PFZ0JTm1L3zQPtQiGAbkz
+sfbrdcOvsjvf8rO4s26jUzBRMB0GA1UdDgQWBBSr8I/Z6oW32AeD1M0eg7SGLCfk
+XDAfBgNVHSMEGDAWgBSr8I/Z6oW32AeD1M0eg7SGLCfkXDAPBgNVHRMBAf8EBTAD
+AQH/MAoGCCqGSM49BAMCA0cAMEQCIAW8rCCtOSosKD6r8lIC9v7T78KqFyOxAcPW
+jPuBnqNZAiAoamZ1j4QhjFWqAxyj5OXGj5TvivOUgSRowzbHETStCA==
+-----END CERTIFICATE-----
+`
+
+// multiValueRDNCert has the subject `C=DE, CN=MULTI Value + OU=Platform  Team, O=Example` encoded as
+// PrintableStrings.
+const multiValueRDNCert = `-----BEGIN CERTIFICATE-----
+MIIBlDCCATsCFDke4W3mE/1nxvKOXW16uEakO3ZRMAoGCCqGSM49BAMCMEwxCzAJ
+BgNVBAYTAkRFMSswEgYDVQQDEwtNVUxUSSBWYWx1ZTAVBgNVBAsTDlBsYXRmb3Jt
+ICBUZWFtMRAwDgYDVQQKEwdFeGFtcGxlMCAXDTI2MTAxNzE3MTU0MVoYDzIxMjYw
+OTIzMTcxNTQxWjBMMQswCQYDVQQGEwJERTErMBIGA1UEAxMLTVVMVEkgVmFsdWUw
+FQYDVQQLEw5QbGF0Zm9ybSAgVGVhbTEQMA4GA1UEChMHRXhhbXBsZTBZMBMGByqG
+SM49AgEGCCqGSM49AwEHA0IABPdrfueNkiHyx36GxDu42zOnA8soqcGmO98qLt9f
+q9goQWYJcgdhuDi6fHptBC8mVnbyDdINOieyKIDuC2duo30wCgYIKoZIzj0EAwID
+RwAwRAIge3EmELx91pTbTjdu0y9wLFvYiZ9b2SsXXfEk1joTA+ACIEmRxcGtNP3q
+GwFW3lw6fAyVN7J2E3O4icWS+6I0qg7q
+-----END CERTIFICATE-----
+`
+
+func TestSubjectHash(t *testing.T) {
+	// The expected hashes are the output of `openssl x509 -noout -subject_hash -subject_hash_old`.
+	tests := []struct {
+		name       string
+		cert       string
+		hash       uint32
+		legacyHash uint32
+	}{
+		{name: "utf8 strings", cert: utf8SubjectCert, hash: 0xbc68557f, legacyHash: 0x313e5fd2},
+		{name: "multi-valued rdn", cert: multiValueRDNCert, hash: 0x5fbc46e7, legacyHash: 0x8dd9d591},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, _ := pem.Decode([]byte(tt.cert))
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hash, err := SubjectHash(cert)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hash != tt.hash {
+				t.Errorf("expected subject hash %08x but got %08x", tt.hash, hash)
+			}
+			if legacyHash := LegacySubjectHash(cert); legacyHash != tt.legacyHash {
+				t.Errorf("expected legacy subject hash %08x but got %08x", tt.legacyHash, legacyHash)
+			}
+		})
+	}
+}
+
+func TestRehash(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a.pem":   []byte(utf8SubjectCert),
+		"b.crt":   []byte(multiValueRDNCert),
+		"dup.pem": []byte(utf8SubjectCert),
+		// Different certificates with the same subject get consecutive link numbers.
+		"same-subject-1.pem": newTestCA(t, "same"),
+		"same-subject-2.pem": newTestCA(t, "same"),
+		"README.md":          []byte(utf8SubjectCert),
+		"not-a-cert.pem":     []byte("not a certificate"),
+		// Files in subdirectories are linked from the directory itself.
+		"nested/dir/c.pem": []byte(multiValueRDNCert),
+		"nested/d.pem":     newTestCA(t, "nested"),
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newFiles, err := Rehash(Options{})(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(newTestCA(t, "same"))
+	sameCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameHash, err := SubjectHash(sameCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameLink := func(n int) string { return fmt.Sprintf("%08x.%d", sameHash, n) }
+
+	block, _ = pem.Decode(files["nested/d.pem"])
+	nestedCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nestedHash, err := SubjectHash(nestedCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLinks := map[string]string{
+		"bc68557f.0": "a.pem",
+		"5fbc46e7.0": "b.crt",
+		sameLink(0):  "same-subject-1.pem",
+		sameLink(1):  "same-subject-2.pem",
+		// The certificate in nested/dir/c.pem duplicates b.crt.
+		fmt.Sprintf("%08x.0", nestedHash): "nested/d.pem",
+	}
+	if want := sets.KeySet(wantLinks); !newFiles.Equal(want) {
+		t.Errorf("expected new files %v but got %v", sets.List(want), sets.List(newFiles))
+	}
+	for link, target := range wantLinks {
+		got, err := os.Readlink(filepath.Join(dir, link))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got != target {
+			t.Errorf("expected %q to link to %q but got %q", link, target, got)
+		}
+	}
+}
+
+func TestRehash_LegacySubjectHash(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.pem"), []byte(utf8SubjectCert), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	newFiles, err := Rehash(Options{LegacySubjectHash: true})(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sets.New("bc68557f.0", "313e5fd2.0"); !newFiles.Equal(want) {
+		t.Errorf("expected new files %v but got %v", sets.List(want), sets.List(newFiles))
+	}
+}
@@ -12,3 +12,6 @@ import (
 type GetCertificatesFunc func(ctx context.Context, meta metadata.Metadata) (map[string][]byte, error)
 
 type WriteCertificatesFunc func(meta metadata.Metadata, cas map[string][]byte) error
+
+// ReleaseVolumeFunc releases resources held for a volume that is no longer managed.
+type ReleaseVolumeFunc func(volumeID string)
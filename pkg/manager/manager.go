@@ -5,21 +5,42 @@ package manager
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	internalapiutil "github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/api/util"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/storage"
 )
 
+const (
+	// DefaultRefreshInterval is the interval used to refresh the trusted CA
+	// certificates of managed volumes if Options.RefreshInterval is not set.
+	DefaultRefreshInterval = 5 * time.Minute
+
+	// DefaultRefreshJitter is the default jitter factor applied to the refresh
+	// interval, used as the default of the `--refresh-jitter` flag.
+	DefaultRefreshJitter = 0.1
+)
+
 // Options used to construct a Manager.
 type Options struct {
 	// Used the read metadata from the storage backend
 	MetadataReader storage.MetadataReader
 
+	// MetadataWriter is optionally used to record the revisions of the sources
+	// the certificates written to each volume were retrieved from.
+	MetadataWriter storage.MetadataWriter
+
 	// Logger used to write log messages
 	Log *logr.Logger
 
@@ -29,6 +50,20 @@ type Options struct {
 	GetCertificates GetCertificatesFunc
 
 	WriteCertificates WriteCertificatesFunc
+
+	// ReleaseVolume is optionally called once a volume is no longer managed,
+	// allowing resources used to retrieve its certificates to be released.
+	ReleaseVolume ReleaseVolumeFunc
+
+	// RefreshInterval is the interval at which the trusted CA certificates of
+	// each managed volume are retrieved again. Defaults to
+	// DefaultRefreshInterval.
+	RefreshInterval time.Duration
+
+	// RefreshJitter is the maximum factor of RefreshInterval that is randomly
+	// added to each refresh interval, spreading load on the sources when many
+	// volumes are managed. Zero disables the jitter.
+	RefreshJitter float64
 }
 
 // NewManager constructs a new manager used to manage volumes containing
@@ -51,19 +86,34 @@ func NewManager(opts Options) (*Manager, error) {
 	if opts.NodeID == "" {
 		return nil, errors.New("nodeID must be set")
 	}
+	if opts.RefreshInterval < 0 {
+		return nil, errors.New("refreshInterval must not be negative")
+	}
+	if opts.RefreshInterval == 0 {
+		opts.RefreshInterval = DefaultRefreshInterval
+	}
+	if opts.RefreshJitter < 0 {
+		return nil, errors.New("refreshJitter must not be negative")
+	}
 	nodeNameHash := internalapiutil.HashIdentifier(opts.NodeID)
 
 	m := &Manager{
 		metadataReader: opts.MetadataReader,
+		metadataWriter: opts.MetadataWriter,
 		log:            *opts.Log,
 
-		managedVolumes: map[string]chan struct{}{},
+		managedVolumes: map[string]*managedVolume{},
 
 		nodeNameHash: nodeNameHash,
 
 		getCertificates: opts.GetCertificates,
 
 		writeCertificates: opts.WriteCertificates,
+
+		releaseVolume: opts.ReleaseVolume,
+
+		refreshInterval: opts.RefreshInterval,
+		refreshJitter:   opts.RefreshJitter,
 	}
 
 	vols, err := opts.MetadataReader.ListVolumes()
@@ -103,14 +153,14 @@ func NewManagerOrDie(opts Options) *Manager {
 type Manager struct {
 	// used to read metadata from the store
 	metadataReader storage.MetadataReader
+	// used to record source revisions in the store, may be nil
+	metadataWriter storage.MetadataWriter
 
 	log logr.Logger
 
 	lock sync.Mutex
 	// global view of all volumes managed by this manager
-	// the stored channel is used to stop management of the
-	// volume
-	managedVolumes map[string]chan struct{}
+	managedVolumes map[string]*managedVolume
 
 	// hash of the node name this driver is running on, used to label CertificateRequest
 	// resources to allow the lister to be scoped to requests for this node only
@@ -119,6 +169,27 @@ type Manager struct {
 	getCertificates GetCertificatesFunc
 
 	writeCertificates WriteCertificatesFunc
+
+	// called once a volume is no longer managed, may be nil
+	releaseVolume ReleaseVolumeFunc
+
+	refreshInterval time.Duration
+	refreshJitter   float64
+}
+
+// managedVolume holds the state of a single volume under management.
+type managedVolume struct {
+	// stopCh is closed to stop management of the volume.
+	stopCh chan struct{}
+	// refreshCh is used to trigger a refresh of the volume before the next
+	// refresh interval has elapsed.
+	refreshCh chan struct{}
+
+	// lock serialises retrieval and writing of the volume's certificates.
+	lock sync.Mutex
+	// filesHash is the hash of the files last written to the volume, used to
+	// skip writing when the retrieved certificates have not changed.
+	filesHash string
 }
 
 // ManageVolumeImmediate will register a volume for management and immediately attempt to retrieve the trusted CA certs.
@@ -127,30 +198,133 @@ func (m *Manager) ManageVolumeImmediate(
 	ctx context.Context,
 	volumeID string,
 ) (managed bool, err error) {
-	if !m.manageVolumeIfNotManaged(volumeID) {
+	vol, ok := m.manageVolumeIfNotManaged(volumeID)
+	if !ok {
 		return false, nil
 	}
 
+	if err := m.refreshVolume(ctx, volumeID, vol); err != nil {
+		return true, err
+	}
+
+	go m.runRefreshLoop(volumeID, vol, false)
+
+	return true, nil
+}
+
+// refreshVolume retrieves the trusted CA certificates for the volume and writes them to the volume if they have
+// changed since they were last written.
+func (m *Manager) refreshVolume(ctx context.Context, volumeID string, vol *managedVolume) error {
+	vol.lock.Lock()
+	defer vol.lock.Unlock()
+
+	select {
+	case <-vol.stopCh:
+		// The volume is no longer managed and its resources may already have been released.
+		return nil
+	default:
+	}
+
 	meta, err := m.metadataReader.ReadMetadata(volumeID)
 	if err != nil {
-		return true, fmt.Errorf("reading metadata: %w", err)
+		return fmt.Errorf("reading metadata: %w", err)
 	}
 
+	ctx, recorder := metadata.WithRevisionRecorder(ctx)
 	files, err := m.getCertificates(ctx, meta)
 	if err != nil {
-		return true, err
+		return err
+	}
+
+	filesHash := hashFiles(files)
+	if filesHash == vol.filesHash {
+		m.log.V(4).Info("Trusted CA certificates unchanged, skipping write", "volume_id", volumeID)
+		return m.recordRevisions(meta, recorder.Revisions())
 	}
 
 	if err := m.writeCertificates(meta, files); err != nil {
-		return true, err
+		return err
 	}
+	vol.filesHash = filesHash
 
-	return true, nil
+	return m.recordRevisions(meta, recorder.Revisions())
+}
+
+// recordRevisions persists the revisions of the sources the volume's certificates were retrieved from in the volume's
+// metadata, if they have changed and a MetadataWriter is configured.
+func (m *Manager) recordRevisions(meta metadata.Metadata, revisions map[string]string) error {
+	if m.metadataWriter == nil || apiequality.Semantic.DeepEqual(meta.Revisions, revisions) {
+		return nil
+	}
+
+	meta.Revisions = revisions
+	if err := m.metadataWriter.WriteMetadata(meta.VolumeID, meta); err != nil {
+		return fmt.Errorf("writing metadata: %w", err)
+	}
+	m.log.V(2).Info("Recorded source revisions", "volume_id", meta.VolumeID, "revisions", revisions)
+
+	return nil
+}
+
+// runRefreshLoop periodically refreshes the trusted CA certificates for the volume until the volume's stop channel is
+// closed. Refreshes also happen when triggered via RefreshAll. If immediate is true, the first refresh happens straight
+// away rather than after the first interval.
+func (m *Manager) runRefreshLoop(volumeID string, vol *managedVolume, immediate bool) {
+	log := m.log.WithValues("volume_id", volumeID)
+
+	ctx, cancel := wait.ContextForChannel(vol.stopCh)
+	defer cancel()
+
+	if !immediate && !m.waitForRefresh(vol) {
+		return
+	}
+
+	for {
+		if err := m.refreshVolume(ctx, volumeID, vol); err != nil {
+			log.Error(err, "failed to refresh trusted CA certificates")
+		}
+
+		if !m.waitForRefresh(vol) {
+			return
+		}
+	}
+}
+
+// waitForRefresh waits until the volume should be refreshed, either because the jittered refresh interval has elapsed
+// or because a refresh has been triggered. It returns false if management of the volume has been stopped.
+func (m *Manager) waitForRefresh(vol *managedVolume) bool {
+	timer := time.NewTimer(wait.Jitter(m.refreshInterval, m.refreshJitter))
+	defer timer.Stop()
+
+	select {
+	case <-vol.stopCh:
+		return false
+	case <-timer.C:
+	case <-vol.refreshCh:
+	}
+	return true
+}
+
+// hashFiles returns a stable hash of the given files, used to determine whether the content of a volume has changed.
+func hashFiles(files map[string][]byte) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%d:%s:%d:", len(name), name, len(files[name]))
+		h.Write(files[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // manageVolumeIfNotManaged will ensure the named volume has been registered for management.
-// It returns 'true' if the volume was not previously managed, and false if the volume was already managed.
-func (m *Manager) manageVolumeIfNotManaged(volumeID string) (managed bool) {
+// It returns the newly managed volume and 'true' if the volume was not previously managed, and false if the volume was
+// already managed.
+func (m *Manager) manageVolumeIfNotManaged(volumeID string) (vol *managedVolume, managed bool) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	log := m.log.WithValues("volume_id", volumeID)
@@ -158,14 +332,17 @@ func (m *Manager) manageVolumeIfNotManaged(volumeID string) (managed bool) {
 	// if the volume is already managed, return early
 	if _, managed := m.managedVolumes[volumeID]; managed {
 		log.V(2).Info("Volume already registered for management")
-		return false
+		return nil, false
 	}
 
 	// construct a new channel used to stop management of the volume
-	stopCh := make(chan struct{})
-	m.managedVolumes[volumeID] = stopCh
+	vol = &managedVolume{
+		stopCh:    make(chan struct{}),
+		refreshCh: make(chan struct{}, 1),
+	}
+	m.managedVolumes[volumeID] = vol
 
-	return true
+	return vol, true
 }
 
 // ManageVolume will initiate management of data for the given volumeID. It will not wait for initial CA cert retrieval
@@ -173,20 +350,33 @@ func (m *Manager) manageVolumeIfNotManaged(volumeID string) (managed bool) {
 // Callers can use `IsVolumeReady` to determine if a CA certificates have been successfully retrieved or not.
 // Upon failure, it is the callers responsibility to call `UnmanageVolume`.
 func (m *Manager) ManageVolume(volumeID string) (managed bool) {
-	if managed := m.manageVolumeIfNotManaged(volumeID); !managed {
+	vol, managed := m.manageVolumeIfNotManaged(volumeID)
+	if !managed {
 		return false
 	}
+
+	go m.runRefreshLoop(volumeID, vol, true)
+
 	return true
 }
 
 func (m *Manager) UnmanageVolume(volumeID string) {
 	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	if stopCh, ok := m.managedVolumes[volumeID]; ok {
-		close(stopCh)
+	vol, ok := m.managedVolumes[volumeID]
+	if ok {
+		close(vol.stopCh)
 		delete(m.managedVolumes, volumeID)
 	}
+	m.lock.Unlock()
+
+	if !ok || m.releaseVolume == nil {
+		return
+	}
+	// Wait for a refresh in progress to complete, so that it cannot use resources of the volume after they have been
+	// released.
+	vol.lock.Lock()
+	defer vol.lock.Unlock()
+	m.releaseVolume(volumeID)
 }
 
 func (m *Manager) IsVolumeReady(volumeID string) bool {
@@ -208,12 +398,57 @@ func (m *Manager) IsVolumeReady(volumeID string) bool {
 	return true
 }
 
-// Stop will stop management of all managed volumes.
-func (m *Manager) Stop() {
+// RefreshAll triggers an immediate refresh of all managed volumes, e.g. when the certificate source has notified that
+// its content has changed. It does not wait for the refreshes to complete.
+func (m *Manager) RefreshAll() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, vol := range m.managedVolumes {
+		select {
+		case vol.refreshCh <- struct{}{}:
+		default:
+			// a refresh is already pending
+		}
+	}
+}
+
+// RefreshVolumes triggers an immediate refresh of the managed volumes with the given IDs, e.g. when the source of their
+// certificates has notified that its content has changed. Volumes that are not managed are ignored. It does not wait
+// for the refreshes to complete.
+func (m *Manager) RefreshVolumes(volumeIDs ...string) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	for k, stopCh := range m.managedVolumes {
-		close(stopCh)
+	for _, volumeID := range volumeIDs {
+		vol, ok := m.managedVolumes[volumeID]
+		if !ok {
+			continue
+		}
+		select {
+		case vol.refreshCh <- struct{}{}:
+		default:
+			// a refresh is already pending
+		}
+	}
+}
+
+// Stop will stop management of all managed volumes and release them, like UnmanageVolume.
+func (m *Manager) Stop() {
+	m.lock.Lock()
+	stopped := make(map[string]*managedVolume, len(m.managedVolumes))
+	for k, vol := range m.managedVolumes {
+		close(vol.stopCh)
 		delete(m.managedVolumes, k)
+		stopped[k] = vol
+	}
+	m.lock.Unlock()
+
+	if m.releaseVolume == nil {
+		return
+	}
+	for volumeID, vol := range stopped {
+		// Wait for a refresh in progress to complete, as in UnmanageVolume.
+		vol.lock.Lock()
+		m.releaseVolume(volumeID)
+		vol.lock.Unlock()
 	}
 }
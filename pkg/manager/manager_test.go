@@ -5,6 +5,9 @@ package manager
 
 import (
 	"context"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -124,3 +127,226 @@ func TestManager_ManageVolume_beginsManagingAndProceedsIfNotReady(t *testing.T)
 		t.Errorf("expected volume to be part of managedVolumes map but it is not")
 	}
 }
+
+func TestManager_RefreshesVolumeWhenCertificatesChange(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		contents = "a"
+		writes   = make(chan map[string][]byte, 10)
+	)
+
+	opts := defaultTestOptions(t, Options{
+		RefreshInterval: 10 * time.Millisecond,
+		GetCertificates: func(context.Context, metadata.Metadata) (map[string][]byte, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return map[string][]byte{"ca.crt": []byte(contents)}, nil
+		},
+		WriteCertificates: func(_ metadata.Metadata, files map[string][]byte) error {
+			writes <- files
+			return nil
+		},
+	})
+	m, err := NewManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	store := opts.MetadataReader.(storage.Interface)
+	meta := metadata.Metadata{
+		VolumeID:   "vol-id",
+		TargetPath: "/fake/path",
+	}
+	if _, err := store.RegisterMetadata(meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.ManageVolumeImmediate(context.Background(), meta.VolumeID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string((<-writes)["ca.crt"]); got != "a" {
+		t.Fatalf("expected initial write of %q but got %q", "a", got)
+	}
+
+	// Unchanged certificates should not be written again.
+	select {
+	case files := <-writes:
+		t.Fatalf("expected no write for unchanged certificates but got %v", files)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	lock.Lock()
+	contents = "b"
+	lock.Unlock()
+
+	select {
+	case files := <-writes:
+		if got := string(files["ca.crt"]); got != "b" {
+			t.Errorf("expected refreshed write of %q but got %q", "b", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for refreshed certificates to be written")
+	}
+}
+
+func TestNewManager_RefreshJitter(t *testing.T) {
+	for _, jitter := range []float64{0, DefaultRefreshJitter, 0.5} {
+		m, err := NewManager(defaultTestOptions(t, Options{RefreshJitter: jitter}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Stop()
+		if m.refreshJitter != jitter {
+			t.Errorf("expected refresh jitter %v but got %v", jitter, m.refreshJitter)
+		}
+	}
+
+	if _, err := NewManager(defaultTestOptions(t, Options{RefreshJitter: -0.1})); err == nil {
+		t.Error("expected an error for a negative refresh jitter")
+	}
+}
+
+func TestManager_RecordsSourceRevisions(t *testing.T) {
+	store := storage.NewMemoryFS()
+	opts := defaultTestOptions(t, Options{
+		MetadataReader: store,
+		MetadataWriter: store,
+		GetCertificates: func(ctx context.Context, _ metadata.Metadata) (map[string][]byte, error) {
+			metadata.RecordRevision(ctx, "oci::example.com/bundle:v1", "sha256:abc")
+			return map[string][]byte{"ca.crt": []byte("a")}, nil
+		},
+	})
+	m, err := NewManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	meta := metadata.Metadata{
+		VolumeID:   "vol-id",
+		TargetPath: "/fake/path",
+	}
+	if _, err := store.RegisterMetadata(meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.ManageVolumeImmediate(context.Background(), meta.VolumeID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	meta, err = store.ReadMetadata(meta.VolumeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := meta.Revisions["oci::example.com/bundle:v1"]; got != "sha256:abc" {
+		t.Errorf("expected recorded revision %q but got %q", "sha256:abc", got)
+	}
+}
+
+func TestManager_UnmanageVolume_ReleasesVolume(t *testing.T) {
+	var released []string
+	opts := defaultTestOptions(t, Options{
+		ReleaseVolume: func(volumeID string) { released = append(released, volumeID) },
+	})
+	m, err := NewManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	store := opts.MetadataReader.(storage.Interface)
+	meta := metadata.Metadata{
+		VolumeID:   "vol-id",
+		TargetPath: "/fake/path",
+	}
+	if _, err := store.RegisterMetadata(meta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ManageVolumeImmediate(context.Background(), meta.VolumeID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.UnmanageVolume(meta.VolumeID)
+	// Unmanaging a volume that is not managed must not release it again.
+	m.UnmanageVolume(meta.VolumeID)
+
+	if len(released) != 1 || released[0] != meta.VolumeID {
+		t.Errorf("expected volume %q to be released once but got %q", meta.VolumeID, released)
+	}
+}
+
+func TestManager_Stop_ReleasesVolumes(t *testing.T) {
+	var released []string
+	opts := defaultTestOptions(t, Options{
+		ReleaseVolume: func(volumeID string) { released = append(released, volumeID) },
+	})
+	m, err := NewManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := opts.MetadataReader.(storage.Interface)
+	for _, volumeID := range []string{"vol-a", "vol-b"} {
+		meta := metadata.Metadata{VolumeID: volumeID, TargetPath: "/fake/" + volumeID}
+		if _, err := store.RegisterMetadata(meta); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.ManageVolumeImmediate(context.Background(), volumeID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	m.Stop()
+	// Stopping again must not release the volumes again.
+	m.Stop()
+
+	sort.Strings(released)
+	if want := []string{"vol-a", "vol-b"}; !reflect.DeepEqual(released, want) {
+		t.Errorf("expected volumes %q to be released once but got %q", want, released)
+	}
+}
+
+func TestManager_RefreshVolumes(t *testing.T) {
+	refreshes := make(chan string, 10)
+	opts := defaultTestOptions(t, Options{
+		RefreshInterval: time.Hour,
+		GetCertificates: func(_ context.Context, meta metadata.Metadata) (map[string][]byte, error) {
+			refreshes <- meta.VolumeID
+			return map[string][]byte{"ca.crt": []byte("a")}, nil
+		},
+	})
+	m, err := NewManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	store := opts.MetadataReader.(storage.Interface)
+	for _, volumeID := range []string{"vol-a", "vol-b"} {
+		meta := metadata.Metadata{VolumeID: volumeID, TargetPath: "/fake/" + volumeID}
+		if _, err := store.RegisterMetadata(meta); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.ManageVolumeImmediate(context.Background(), volumeID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		<-refreshes
+	}
+
+	m.RefreshVolumes("vol-b", "not-managed")
+
+	select {
+	case got := <-refreshes:
+		if got != "vol-b" {
+			t.Errorf("expected %q to be refreshed but got %q", "vol-b", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the volume to be refreshed")
+	}
+	select {
+	case got := <-refreshes:
+		t.Errorf("expected no other volume to be refreshed but got %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
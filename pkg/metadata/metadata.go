@@ -20,6 +20,11 @@ type Metadata struct {
 	// System-specific attributes extracted from the NodePublishVolume request.
 	// These are sourced from the VolumeContext.
 	VolumeContext map[string]string `json:"volumeContext,omitempty"`
+
+	// Revisions records the revision (e.g. the resolved OCI manifest digest) of
+	// each source the files currently in the volume were retrieved from, keyed
+	// by source.
+	Revisions map[string]string `json:"revisions,omitempty"`
 }
 
 // FromNodePublishVolumeRequest constructs a Metadata from a NodePublishVolumeRequest.
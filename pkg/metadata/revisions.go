@@ -0,0 +1,54 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"sync"
+)
+
+type revisionRecorderKey struct{}
+
+// RevisionRecorder collects the revisions of the sources files are retrieved from.
+type RevisionRecorder struct {
+	lock      sync.Mutex
+	revisions map[string]string
+}
+
+// WithRevisionRecorder returns a context carrying a new RevisionRecorder, which sources record their revisions to via
+// RecordRevision.
+func WithRevisionRecorder(ctx context.Context) (context.Context, *RevisionRecorder) {
+	r := &RevisionRecorder{}
+	return context.WithValue(ctx, revisionRecorderKey{}, r), r
+}
+
+// RecordRevision records the revision of the source to the RevisionRecorder carried by the context, if any.
+func RecordRevision(ctx context.Context, source, revision string) {
+	r, ok := ctx.Value(revisionRecorderKey{}).(*RevisionRecorder)
+	if !ok {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.revisions == nil {
+		r.revisions = map[string]string{}
+	}
+	r.revisions[source] = revision
+}
+
+// Revisions returns the recorded revisions, or nil if none have been recorded.
+func (r *RevisionRecorder) Revisions() map[string]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.revisions) == 0 {
+		return nil
+	}
+	revisions := make(map[string]string, len(r.revisions))
+	for source, revision := range r.revisions {
+		revisions[source] = revision
+	}
+	return revisions
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package pki
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // Required to match NSS trust objects to certificates.
+	"crypto/x509"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	certdataClassCertificate = "CKO_CERTIFICATE"
+	certdataClassTrust       = "CKO_NSS_TRUST"
+
+	// certdataTrustedDelegator is the trust value of certificates trusted to issue certificates for the purpose.
+	certdataTrustedDelegator = "CKT_NSS_TRUSTED_DELEGATOR"
+
+	// certdataFalse is the value of boolean attributes that are not set, e.g. of CKA_NSS_SERVER_DISTRUST_AFTER for
+	// certificates that are not partially distrusted.
+	certdataFalse = "CK_FALSE"
+)
+
+// MozillaCertificate is a certificate from the Mozilla root store.
+type MozillaCertificate struct {
+	// Label is the name of the certificate in the root store.
+	Label string
+	// Certificate is the parsed certificate.
+	Certificate *x509.Certificate
+}
+
+// certdataObject is a PKCS#11 object from a certdata.txt file, mapping attribute names to their values. Octal values
+// are decoded to their raw bytes, other values are kept as written without their type.
+type certdataObject map[string]string
+
+// ParseCertdata parses the certificates in the NSS certdata.txt format used by the Mozilla root store, returning only
+// the certificates whose trust object trusts them to issue server authentication certificates. Certificates trusted
+// only for other purposes, e.g. email protection, and distrusted certificates are skipped.
+//
+// Certificates with a CKA_NSS_SERVER_DISTRUST_AFTER date are skipped as well. NSS does not trust server certificates
+// they issued after that date, which cannot be expressed in the trust stores written to volumes, so writing them would
+// trust them fully.
+func ParseCertdata(r io.Reader) ([]MozillaCertificate, error) {
+	objects, err := parseCertdataObjects(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// serverAuthTrust holds the server authentication trust of each certificate, keyed by SHA1 fingerprint.
+	serverAuthTrust := map[string]string{}
+	for _, o := range objects {
+		if o["CKA_CLASS"] == certdataClassTrust {
+			serverAuthTrust[o["CKA_CERT_SHA1_HASH"]] = o["CKA_TRUST_SERVER_AUTH"]
+		}
+	}
+
+	var certs []MozillaCertificate
+	for _, o := range objects {
+		if o["CKA_CLASS"] != certdataClassCertificate {
+			continue
+		}
+		if distrustAfter, ok := o["CKA_NSS_SERVER_DISTRUST_AFTER"]; ok && distrustAfter != certdataFalse {
+			continue
+		}
+		der := []byte(o["CKA_VALUE"])
+		fingerprint := sha1.Sum(der) //nolint:gosec // Required to match NSS trust objects to certificates.
+		if serverAuthTrust[string(fingerprint[:])] != certdataTrustedDelegator {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate %q: %w", o["CKA_LABEL"], err)
+		}
+		certs = append(certs, MozillaCertificate{Label: o["CKA_LABEL"], Certificate: cert})
+	}
+
+	return certs, nil
+}
+
+// parseCertdataObjects parses the objects following the BEGINDATA line. Each object starts with a CKA_CLASS
+// attribute.
+func parseCertdataObjects(r io.Reader) ([]certdataObject, error) {
+	var (
+		objects []certdataObject
+		current certdataObject
+		inData  bool
+		lineNum int
+	)
+
+	scanner := bufio.NewScanner(r)
+	// Multiline values are read line by line, so the default maximum line length suffices.
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !inData {
+			inData = line == "BEGINDATA"
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: invalid attribute %q", lineNum, line)
+		}
+		name, typ := fields[0], fields[1]
+
+		if name == "CKA_CLASS" {
+			current = certdataObject{}
+			objects = append(objects, current)
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: attribute %s outside of an object", lineNum, name)
+		}
+
+		switch typ {
+		case "MULTILINE_OCTAL":
+			value, n, err := readCertdataOctal(scanner)
+			lineNum += n
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value of %s: %w", lineNum, name, err)
+			}
+			current[name] = string(value)
+		case "UTF8":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: missing value of %s", lineNum, name)
+			}
+			value, err := strconv.Unquote(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value of %s: %w", lineNum, name, err)
+			}
+			current[name] = value
+		default:
+			if len(fields) == 3 {
+				current[name] = fields[2]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// readCertdataOctal reads the lines of octal escaped bytes up to the END line, returning the decoded bytes and the
+// number of lines read.
+func readCertdataOctal(scanner *bufio.Scanner) ([]byte, int, error) {
+	var (
+		value []byte
+		lines int
+	)
+	for scanner.Scan() {
+		lines++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "END" {
+			return value, lines, nil
+		}
+		octals := strings.Split(line, `\`)
+		if octals[0] != "" {
+			return nil, lines, fmt.Errorf("invalid octal line %q", line)
+		}
+		for _, octal := range octals[1:] {
+			b, err := strconv.ParseUint(octal, 8, 8)
+			if err != nil {
+				return nil, lines, fmt.Errorf("invalid octal byte %q", octal)
+			}
+			value = append(value, byte(b))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, lines, err
+	}
+	return nil, lines, io.ErrUnexpectedEOF
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package pki
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/testcertdata"
+)
+
+func TestParseCertdata(t *testing.T) {
+	now := time.Now()
+	serverAuth := newTestCertificate(t, "server auth", true, now.Add(-time.Hour), now.Add(time.Hour))
+	emailOnly := newTestCertificate(t, "email only", true, now.Add(-time.Hour), now.Add(time.Hour))
+	distrusted := newTestCertificate(t, "distrusted", true, now.Add(-time.Hour), now.Add(time.Hour))
+	distrustedAfter := newTestCertificate(t, "distrusted after", true, now.Add(-time.Hour), now.Add(time.Hour))
+	withoutTrust := newTestCertificate(t, "without trust", true, now.Add(-time.Hour), now.Add(time.Hour))
+
+	certdata := "# This Source Code Form is subject to the terms of the Mozilla Public\n" +
+		"CVS_ID \"@(#) $RCSfile$ $Revision$ $Date$\"\n" +
+		"\nBEGINDATA\n" +
+		"CKA_CLASS CK_OBJECT_CLASS CKO_NSS_BUILTIN_ROOT_LIST\n" +
+		"CKA_LABEL UTF8 \"Mozilla Builtin Roots\"\n" +
+		testcertdata.Objects(serverAuth, "Server \\\"Auth\\\" CA", certdataTrustedDelegator, "CKT_NSS_MUST_VERIFY_TRUST") +
+		testcertdata.Objects(emailOnly, "Email CA", "CKT_NSS_MUST_VERIFY_TRUST", certdataTrustedDelegator) +
+		testcertdata.Objects(distrusted, "Distrusted CA", "CKT_NSS_NOT_TRUSTED", "CKT_NSS_NOT_TRUSTED") +
+		testcertdata.ObjectsDistrustedAfter(
+			distrustedAfter, "Distrusted After CA", certdataTrustedDelegator, certdataTrustedDelegator, now,
+		) +
+		testcertdata.Objects(withoutTrust, "Without Trust CA", "", "")
+	// Drop the trust object of the last certificate.
+	certdata = certdata[:strings.LastIndex(certdata, "CKA_CLASS CK_OBJECT_CLASS CKO_NSS_TRUST")]
+
+	certs, err := ParseCertdata(strings.NewReader(certdata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MozillaCertificate{{Label: `Server "Auth" CA`, Certificate: serverAuth}}
+	if !reflect.DeepEqual(certs, want) {
+		t.Errorf("expected only the server authentication CA but got %v", certs)
+	}
+}
+
+func TestParseCertdata_Invalid(t *testing.T) {
+	const certificate = "BEGINDATA\nCKA_CLASS CK_OBJECT_CLASS CKO_CERTIFICATE\nCKA_VALUE MULTILINE_OCTAL\n"
+	for name, certdata := range map[string]string{
+		"unterminated octal":             certificate + "\\060\\202\n",
+		"invalid octal":                  certificate + "\\999\nEND\n",
+		"attribute outside of an object": "BEGINDATA\nCKA_LABEL UTF8 \"a\"\n",
+	} {
+		certdata := certdata
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseCertdata(strings.NewReader(certdata)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/multierr"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Input formats detected by Normalizer.
+const (
+	FormatPEM    = "PEM"
+	FormatDER    = "DER"
+	FormatPKCS7  = "PKCS#7"
+	FormatPKCS12 = "PKCS#12"
+)
+
+const pemTypePKCS7 = "PKCS7"
+
+// oidSignedData is the PKCS#7 signedData content type, used for certificate bundles (`.p7b` files).
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// binaryExtensions are the extensions of binary certificate formats. Normalized files with these extensions are
+// renamed to `.pem`, as they no longer contain the binary format.
+var binaryExtensions = map[string]bool{".der": true, ".p7b": true, ".p7c": true, ".p12": true, ".pfx": true}
+
+// FormatError is returned when a file detected as a certificate format cannot be parsed.
+type FormatError struct {
+	// File is the name of the file that could not be parsed.
+	File string
+	// Format is the detected format of the file.
+	Format string
+	// Err is the parse error.
+	Err error
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("failed to parse %q as %s: %v", e.File, e.Format, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// NormalizationOptions configure a Normalizer.
+type NormalizationOptions struct {
+	// PKCS12Passwords are the passwords tried, in order, to decode PKCS#12 files. The empty password is always tried
+	// first.
+	PKCS12Passwords []string
+}
+
+// Normalizer converts files retrieved from sources into PEM files.
+type Normalizer struct {
+	log  logr.Logger
+	opts NormalizationOptions
+}
+
+// NewNormalizer returns a Normalizer with the given options, logging converted files to log.
+func NewNormalizer(log logr.Logger, opts NormalizationOptions) *Normalizer {
+	return &Normalizer{
+		log:  log,
+		opts: opts,
+	}
+}
+
+// Normalize detects the format of each file and re-encodes the certificates in PEM, DER, PKCS#7 and PKCS#12 files as
+// one PEM block per certificate with LF line endings. Files that are not in any of these formats, or that contain PEM
+// blocks other than certificates, are returned unchanged so that they are rejected by validation. An error wrapping a
+// FormatError for each file is returned if files detected as one of these formats cannot be parsed.
+func (n *Normalizer) Normalize(files map[string][]byte) (map[string][]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs error
+	normalized := make(map[string][]byte, len(files))
+	for _, name := range names {
+		format, certs, err := n.parse(name, files[name])
+		if err != nil {
+			errs = multierr.Append(errs, &FormatError{File: name, Format: format, Err: err})
+			continue
+		}
+
+		outName, data := name, files[name]
+		if certs != nil {
+			data = encodeCertificates(certs)
+			if binaryExtensions[strings.ToLower(filepath.Ext(name))] {
+				outName = strings.TrimSuffix(name, filepath.Ext(name)) + ".pem"
+			}
+			n.log.V(4).Info("Normalized file", "file", name, "format", format, "certificates", len(certs))
+		}
+		if _, ok := normalized[outName]; ok {
+			errs = multierr.Append(errs, fmt.Errorf("normalized file %q from %q conflicts with another file", outName, name))
+			continue
+		}
+		normalized[outName] = data
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	return normalized, nil
+}
+
+// parse returns the detected format of the file and the certificates it contains. Nil certificates and a nil error
+// are returned if the file is not in a supported format.
+func (n *Normalizer) parse(name string, data []byte) (string, []*x509.Certificate, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".p12", ".pfx":
+		certs, err := n.parsePKCS12(data)
+		return FormatPKCS12, certs, err
+	case ".p7b", ".p7c":
+		if !bytes.Contains(data, []byte("-----BEGIN ")) {
+			certs, err := parsePKCS7(data)
+			return FormatPKCS7, certs, err
+		}
+	}
+
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		certs, err := parsePEM(data)
+		return FormatPEM, certs, err
+	}
+
+	if certs, err := x509.ParseCertificates(data); err == nil && len(certs) > 0 {
+		return FormatDER, certs, nil
+	}
+	if certs, err := parsePKCS7(data); err == nil {
+		return FormatPKCS7, certs, nil
+	}
+
+	return "", nil, nil
+}
+
+func (n *Normalizer) parsePKCS12(data []byte) ([]*x509.Certificate, error) {
+	var errs error
+	for _, password := range append([]string{""}, n.opts.PKCS12Passwords...) {
+		certs, err := pkcs12.DecodeTrustStore(data, password)
+		if err == nil {
+			return certs, nil
+		}
+		errs = multierr.Append(errs, err)
+	}
+	if errors.Is(errs, pkcs12.ErrIncorrectPassword) {
+		return nil, errors.New("none of the configured passwords decrypt the truststore")
+	}
+	return nil, errs
+}
+
+// parsePEM returns the certificates in the PEM data, extracting them from PKCS#7 blocks. Nil certificates are
+// returned if the data contains blocks other than certificates.
+func parsePEM(data []byte) ([]*x509.Certificate, error) {
+	var (
+		certs []*x509.Certificate
+		block *pem.Block
+		rest  = data
+	)
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case pemTypeCertificate:
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		case pemTypePKCS7:
+			p7Certs, err := parsePKCS7(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, p7Certs...)
+		default:
+			return nil, nil
+		}
+	}
+	return certs, nil
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// parsePKCS7 returns the certificates in the DER encoded PKCS#7 signedData structure.
+func parsePKCS7(data []byte) ([]*x509.Certificate, error) {
+	var contentInfo pkcs7ContentInfo
+	if rest, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+		return nil, err
+	} else if len(bytes.TrimRight(rest, "\x00")) > 0 {
+		return nil, errors.New("trailing data")
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unsupported content type %s", contentInfo.ContentType)
+	}
+
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("invalid signed data: %w", err)
+	}
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("contains no certificates")
+	}
+	return certs, nil
+}
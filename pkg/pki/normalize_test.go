@@ -0,0 +1,153 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package pki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	now := time.Now()
+	caA := newTestCertificate(t, "a", true, now.Add(-time.Hour), now.Add(time.Hour))
+	caB := newTestCertificate(t, "b", true, now.Add(-time.Hour), now.Add(time.Hour))
+	bothPEM := append(encodePEM(caA), encodePEM(caB)...)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	p7b := newTestPKCS7(t, caA, caB)
+	p12, err := pkcs12.EncodeTrustStore(rand.Reader, []*x509.Certificate{caA, caB}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		opts          NormalizationOptions
+		files         map[string][]byte
+		want          map[string][]byte
+		wantFormatErr string
+	}{{
+		name:  "CRLF PEM",
+		files: map[string][]byte{"a.crt": bytes.ReplaceAll(bothPEM, []byte("\n"), []byte("\r\n"))},
+		want:  map[string][]byte{"a.crt": bothPEM},
+	}, {
+		name:  "PEM with surrounding text",
+		files: map[string][]byte{"a.crt": append([]byte("subject=CN = a\n"), encodePEM(caA)...)},
+		want:  map[string][]byte{"a.crt": encodePEM(caA)},
+	}, {
+		name:  "DER",
+		files: map[string][]byte{"a.der": caA.Raw, "b.cer": caB.Raw},
+		want:  map[string][]byte{"a.pem": encodePEM(caA), "b.cer": encodePEM(caB)},
+	}, {
+		name:  "PKCS#7",
+		files: map[string][]byte{"roots.p7b": p7b},
+		want:  map[string][]byte{"roots.pem": bothPEM},
+	}, {
+		name:  "PEM encoded PKCS#7",
+		files: map[string][]byte{"roots.p7b": pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: p7b})},
+		want:  map[string][]byte{"roots.pem": bothPEM},
+	}, {
+		name:  "PKCS#12",
+		opts:  NormalizationOptions{PKCS12Passwords: []string{"changeit", "secret"}},
+		files: map[string][]byte{"truststore.p12": p12},
+		want:  map[string][]byte{"truststore.pem": bothPEM},
+	}, {
+		name:  "files in other formats are unchanged",
+		files: map[string][]byte{"key.pem": privateKey, "README.md": []byte("hello")},
+		want:  map[string][]byte{"key.pem": privateKey, "README.md": []byte("hello")},
+	}, {
+		name:          "invalid PKCS#7",
+		files:         map[string][]byte{"a.crt": encodePEM(caA), "roots.p7b": []byte("not a bundle")},
+		wantFormatErr: "roots.p7b",
+	}, {
+		name:          "PKCS#12 with unknown password",
+		files:         map[string][]byte{"truststore.p12": p12},
+		wantFormatErr: "truststore.p12",
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewNormalizer(logr.Discard(), tt.opts).Normalize(tt.files)
+			if tt.wantFormatErr != "" {
+				var formatErr *FormatError
+				if !errors.As(err, &formatErr) || formatErr.File != tt.wantFormatErr {
+					t.Fatalf("expected format error for %q but got: %v", tt.wantFormatErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizer_Normalize_Conflict(t *testing.T) {
+	now := time.Now()
+	ca := newTestCertificate(t, "ca", true, now.Add(-time.Hour), now.Add(time.Hour))
+
+	_, err := NewNormalizer(logr.Discard(), NormalizationOptions{}).Normalize(
+		map[string][]byte{"ca.pem": encodePEM(ca), "ca.der": ca.Raw},
+	)
+	if err == nil {
+		t.Errorf("expected an error when a normalized file name conflicts with another file")
+	}
+}
+
+// newTestPKCS7 returns a DER encoded PKCS#7 certificate bundle, as created by
+// `openssl crl2pkcs7 -nocrl -certfile <file> -outform DER`.
+func newTestPKCS7(t *testing.T, certs ...*x509.Certificate) []byte {
+	t.Helper()
+
+	var raw []byte
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw...)
+	}
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	data, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedData, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      asn1.RawValue{FullBytes: data},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:      emptySet,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p7
+}
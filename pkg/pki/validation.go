@@ -0,0 +1,181 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pki normalizes, validates and filters the trusted CA certificates retrieved from sources before they are
+// written to volumes.
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	pemTypeCertificate = "CERTIFICATE"
+
+	// DefaultMaxBundleSize is the default maximum total size in bytes of all files written to a volume.
+	DefaultMaxBundleSize = 1 << 20
+)
+
+// ErrNoCertificates is returned when no valid certificates remain after validation.
+var ErrNoCertificates = errors.New("no valid certificates")
+
+// ValidationOptions configure which certificates are dropped by a Validator. Files containing anything other than
+// certificates, e.g. private keys, are always rejected.
+type ValidationOptions struct {
+	// DropExpired drops certificates that are expired or not yet valid.
+	DropExpired bool
+
+	// DropNonCA drops certificates that are not CA certificates, e.g. leaf certificates.
+	DropNonCA bool
+
+	// MaxBundleSize is the maximum total size in bytes of all validated files. Zero means no limit.
+	MaxBundleSize int
+}
+
+// Validator validates and filters files retrieved from sources.
+type Validator struct {
+	log  logr.Logger
+	opts ValidationOptions
+
+	// now returns the current time, used to check certificate validity periods.
+	now func() time.Time
+}
+
+// NewValidator returns a Validator with the given options, logging rejected files and certificates to log.
+func NewValidator(log logr.Logger, opts ValidationOptions) *Validator {
+	return &Validator{
+		log:  log,
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// Validate parses the PEM or DER encoded certificates in each file and returns the files that contain valid
+// certificates. Files containing PEM blocks other than certificates, or that cannot be parsed, are rejected. Dropped
+// certificates are removed from their file, which is re-encoded as PEM. An error is returned if no file remains or
+// the remaining files exceed the maximum bundle size.
+func (v *Validator) Validate(files map[string][]byte) (map[string][]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	validated := make(map[string][]byte, len(files))
+	size := 0
+	for _, name := range names {
+		data, ok := v.validateFile(name, files[name])
+		if !ok {
+			continue
+		}
+		validated[name] = data
+		size += len(data)
+	}
+
+	if len(validated) == 0 && len(files) > 0 {
+		return nil, ErrNoCertificates
+	}
+	if v.opts.MaxBundleSize > 0 && size > v.opts.MaxBundleSize {
+		return nil, fmt.Errorf(
+			"trusted certificates size of %d bytes exceeds maximum bundle size of %d bytes", size, v.opts.MaxBundleSize,
+		)
+	}
+
+	return validated, nil
+}
+
+// validateFile returns the validated contents of the file and whether the file should be kept.
+func (v *Validator) validateFile(name string, data []byte) ([]byte, bool) {
+	log := v.log.WithValues("file", name)
+
+	certs, err := ParseCertificates(data)
+	if err != nil {
+		log.Info("Rejecting file", "reason", err.Error())
+		return nil, false
+	}
+
+	kept := make([]*x509.Certificate, 0, len(certs))
+	for _, cert := range certs {
+		if reason := v.dropReason(cert); reason != "" {
+			log.Info("Dropping certificate", "subject", cert.Subject.String(), "reason", reason)
+			continue
+		}
+		kept = append(kept, cert)
+	}
+
+	switch {
+	case len(kept) == 0:
+		log.Info("Rejecting file", "reason", "no valid certificates")
+		return nil, false
+	case len(kept) == len(certs):
+		return data, true
+	default:
+		return encodeCertificates(kept), true
+	}
+}
+
+// dropReason returns why the certificate should be dropped, or an empty string if it should be kept.
+func (v *Validator) dropReason(cert *x509.Certificate) string {
+	if v.opts.DropExpired {
+		now := v.now()
+		if now.After(cert.NotAfter) {
+			return fmt.Sprintf("expired at %s", cert.NotAfter.Format(time.RFC3339))
+		}
+		if now.Before(cert.NotBefore) {
+			return fmt.Sprintf("not valid before %s", cert.NotBefore.Format(time.RFC3339))
+		}
+	}
+	if v.opts.DropNonCA && !(cert.BasicConstraintsValid && cert.IsCA) {
+		return "not a CA certificate"
+	}
+	return ""
+}
+
+// ParseCertificates parses the PEM encoded certificates in data, or the DER encoded certificates if data does not
+// contain any PEM blocks. PEM blocks other than certificates are rejected.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var (
+		certs []*x509.Certificate
+		block *pem.Block
+		rest  = data
+	)
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != pemTypeCertificate {
+			return nil, fmt.Errorf("contains unsupported PEM block of type %q", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	certs, err := x509.ParseCertificates(data)
+	if err != nil || len(certs) == 0 {
+		return nil, errors.New("contains neither PEM nor DER encoded certificates")
+	}
+	return certs, nil
+}
+
+func encodeCertificates(certs []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		_ = pem.Encode(&buf, &pem.Block{Type: pemTypeCertificate, Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	now := time.Now()
+	ca := newTestCertificate(t, "ca", true, now.Add(-time.Hour), now.Add(time.Hour))
+	expiredCA := newTestCertificate(t, "expired", true, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	leaf := newTestCertificate(t, "leaf", false, now.Add(-time.Hour), now.Add(time.Hour))
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	tests := []struct {
+		name    string
+		opts    ValidationOptions
+		files   map[string][]byte
+		want    map[string][]byte
+		wantErr error
+	}{{
+		name:  "valid PEM and DER certificates",
+		files: map[string][]byte{"a.crt": encodePEM(ca), "b.der": ca.Raw},
+		want:  map[string][]byte{"a.crt": encodePEM(ca), "b.der": ca.Raw},
+	}, {
+		name:  "rejects private keys",
+		files: map[string][]byte{"a.crt": encodePEM(ca), "key.pem": append(encodePEM(ca), privateKey...)},
+		want:  map[string][]byte{"a.crt": encodePEM(ca)},
+	}, {
+		name:  "rejects unparseable files",
+		files: map[string][]byte{"a.crt": encodePEM(ca), "typo": []byte("not a certificate")},
+		want:  map[string][]byte{"a.crt": encodePEM(ca)},
+	}, {
+		name:  "keeps expired and leaf certificates by default",
+		files: map[string][]byte{"a.crt": append(encodePEM(expiredCA), encodePEM(leaf)...)},
+		want:  map[string][]byte{"a.crt": append(encodePEM(expiredCA), encodePEM(leaf)...)},
+	}, {
+		name:  "drops expired certificates",
+		opts:  ValidationOptions{DropExpired: true},
+		files: map[string][]byte{"a.crt": append(encodePEM(expiredCA), encodePEM(ca)...), "b.crt": encodePEM(expiredCA)},
+		want:  map[string][]byte{"a.crt": encodePEM(ca)},
+	}, {
+		name:  "drops non-CA certificates",
+		opts:  ValidationOptions{DropNonCA: true},
+		files: map[string][]byte{"a.crt": append(encodePEM(leaf), encodePEM(ca)...)},
+		want:  map[string][]byte{"a.crt": encodePEM(ca)},
+	}, {
+		name:    "no valid certificates",
+		files:   map[string][]byte{"key.pem": privateKey},
+		wantErr: ErrNoCertificates,
+	}, {
+		name:    "exceeds maximum bundle size",
+		opts:    ValidationOptions{MaxBundleSize: len(encodePEM(ca)) - 1},
+		files:   map[string][]byte{"a.crt": encodePEM(ca)},
+		wantErr: errors.New("exceeds maximum bundle size"),
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewValidator(logr.Discard(), tt.opts).Validate(tt.files)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if errors.Is(tt.wantErr, ErrNoCertificates) && !errors.Is(err, ErrNoCertificates) {
+				t.Fatalf("expected ErrNoCertificates but got: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestCertificate(t *testing.T, cn string, isCA bool, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func encodePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
@@ -0,0 +1,275 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/containerd/containerd/images"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/multierr"
+	"oras.land/oras-go/pkg/content"
+)
+
+const (
+	// MediaTypeBundle is the media type of layers holding a tarball of trusted CA certificates.
+	MediaTypeBundle = "application/vnd.d2iq.trusted-ca.bundle.v1.tar"
+	// MediaTypeBundleGzip is the media type of layers holding a gzipped tarball of trusted CA certificates.
+	MediaTypeBundleGzip = MediaTypeBundle + "+gzip"
+	// MediaTypeBundleZstd is the media type of layers holding a zstd compressed tarball of trusted CA certificates.
+	MediaTypeBundleZstd = MediaTypeBundle + "+zstd"
+)
+
+type compression string
+
+const (
+	uncompressed    compression = ""
+	compressionGzip compression = "gzip"
+	compressionZstd compression = "zstd"
+)
+
+// tarballMediaTypes are the media types of layers holding tarballs, and how the tarballs are compressed.
+var tarballMediaTypes = map[string]compression{
+	ocispecv1.MediaTypeImageLayer:          uncompressed,
+	ocispecv1.MediaTypeImageLayerGzip:      compressionGzip,
+	ocispecv1.MediaTypeImageLayerZstd:      compressionZstd,
+	images.MediaTypeDockerSchema2Layer:     uncompressed,
+	images.MediaTypeDockerSchema2LayerGzip: compressionGzip,
+	MediaTypeBundle:                        uncompressed,
+	MediaTypeBundleGzip:                    compressionGzip,
+	MediaTypeBundleZstd:                    compressionZstd,
+}
+
+// BundleLimits limit the size of bundles. They are enforced while the layers are decompressed and written to the
+// bundle, so that oversized bundles, e.g. compression bombs, are rejected before they are written anywhere.
+type BundleLimits struct {
+	// MaxFileSize is the maximum size in bytes of a single file.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum size in bytes of all files.
+	MaxTotalSize int64
+	// MaxEntries is the maximum number of entries, including directories.
+	MaxEntries int
+}
+
+// DefaultBundleLimits are the limits of clients not configured with ClientOptBundleLimits.
+var DefaultBundleLimits = BundleLimits{
+	MaxFileSize:  4 << 20,
+	MaxTotalSize: 16 << 20,
+	MaxEntries:   4096,
+}
+
+// BundleLimitError is returned if a bundle exceeds its limits.
+type BundleLimitError struct {
+	// Entry is the name of the entry exceeding the limits.
+	Entry string
+	// Reason describes which limit has been exceeded.
+	Reason string
+}
+
+func (e *BundleLimitError) Error() string {
+	return fmt.Sprintf("bundle limit exceeded: entry %q: %s", e.Entry, e.Reason)
+}
+
+// bundleLayer is a layer contributing files to the bundle.
+type bundleLayer struct {
+	desc ocispecv1.Descriptor
+	// tarball is true if the layer is a tarball whose files are added to the bundle. Otherwise the layer is a single
+	// file named by its title annotation.
+	tarball     bool
+	compression compression
+}
+
+// newBundleLayer returns how the layer contributes files to the bundle, or false if it does not contribute any files.
+//
+// Layers are tarballs if they have a tarball media type and either have no title annotation, have the project's own
+// bundle media type, are a directory pushed by oras, or are titled like a tarball. Other layers with a title annotation
+// are single files, as pushed by `oras push <ref> <file>...`, which uses the tarball media type for files by default.
+func newBundleLayer(desc ocispecv1.Descriptor) (bundleLayer, bool) {
+	title := desc.Annotations[ocispecv1.AnnotationTitle]
+
+	if c, ok := tarballMediaTypes[desc.MediaType]; ok {
+		if title == "" ||
+			strings.HasPrefix(desc.MediaType, MediaTypeBundle) ||
+			desc.Annotations[content.AnnotationUnpack] == "true" ||
+			hasTarballSuffix(title) {
+			return bundleLayer{desc: desc, tarball: true, compression: c}, true
+		}
+	}
+
+	if title != "" {
+		return bundleLayer{desc: desc}, true
+	}
+
+	return bundleLayer{}, false
+}
+
+func hasTarballSuffix(name string) bool {
+	for _, suffix := range []string{".tar", ".tar.gz", ".tgz", ".tar.zst", ".tar.zstd"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleLayers returns the layers contributing files to the bundle of the manifest described by desc, in manifest
+// order. Image indexes are followed to all the manifests they list, in index order.
+func bundleLayers(ctx context.Context, store *content.Memory, desc ocispecv1.Descriptor) ([]bundleLayer, error) {
+	rc, err := store.Fetch(ctx, desc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve manifest with digest %s: %w", desc.Digest, err)
+	}
+	data, err := io.ReadAll(rc)
+	if err = multierr.Combine(err, rc.Close()); err != nil {
+		return nil, fmt.Errorf("unable to read manifest with digest %s: %w", desc.Digest, err)
+	}
+
+	switch desc.MediaType {
+	case ocispecv1.MediaTypeImageIndex, images.MediaTypeDockerSchema2ManifestList:
+		var index ocispecv1.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("invalid image index with digest %s: %w", desc.Digest, err)
+		}
+		var layers []bundleLayer
+		for _, m := range index.Manifests {
+			manifestLayers, err := bundleLayers(ctx, store, m)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, manifestLayers...)
+		}
+		return layers, nil
+	case ocispecv1.MediaTypeImageManifest, images.MediaTypeDockerSchema2Manifest:
+		var manifest ocispecv1.Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest with digest %s: %w", desc.Digest, err)
+		}
+		var layers []bundleLayer
+		for _, l := range manifest.Layers {
+			if layer, ok := newBundleLayer(l); ok {
+				layers = append(layers, layer)
+			}
+		}
+		return layers, nil
+	default:
+		return nil, fmt.Errorf("unsupported manifest media type %q of %s", desc.MediaType, desc.Digest)
+	}
+}
+
+// writeBundle writes a single tarball of the files of all layers to w, within the limits. Files of later layers
+// replace files with the same name of earlier layers when the tarball is read.
+func writeBundle(
+	ctx context.Context,
+	store *content.Memory,
+	layers []bundleLayer,
+	limits BundleLimits,
+	w io.Writer,
+) error {
+	bw := &bundleWriter{tw: tar.NewWriter(w), limits: limits}
+	for _, layer := range layers {
+		if err := bw.writeLayer(ctx, store, layer); err != nil {
+			return fmt.Errorf("unable to read layer with digest %s: %w", layer.desc.Digest, err)
+		}
+	}
+	return bw.tw.Close()
+}
+
+// bundleWriter writes the files of layers to a bundle tarball, enforcing the limits across all layers.
+type bundleWriter struct {
+	tw     *tar.Writer
+	limits BundleLimits
+
+	entries   int
+	totalSize int64
+}
+
+func (w *bundleWriter) writeLayer(ctx context.Context, store *content.Memory, layer bundleLayer) error {
+	rc, err := store.Fetch(ctx, layer.desc)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+
+	if !layer.tarball {
+		return w.writeFile(&tar.Header{
+			Name:     layer.desc.Annotations[ocispecv1.AnnotationTitle],
+			Mode:     0o644,
+			Size:     layer.desc.Size,
+			Typeflag: tar.TypeReg,
+		}, rc)
+	}
+
+	r, err := decompress(rc, layer.compression)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.writeFile(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile writes the entry with the content read from r to the bundle, if it is within the limits. The tar writer
+// rejects content longer than the size in the header, so the size checked is the size written.
+func (w *bundleWriter) writeFile(header *tar.Header, r io.Reader) error {
+	w.entries++
+	if w.entries > w.limits.MaxEntries {
+		return &BundleLimitError{
+			Entry:  header.Name,
+			Reason: fmt.Sprintf("bundle has more than %d entries", w.limits.MaxEntries),
+		}
+	}
+	if header.Size > w.limits.MaxFileSize {
+		return &BundleLimitError{
+			Entry:  header.Name,
+			Reason: fmt.Sprintf("file size %d exceeds the limit of %d bytes", header.Size, w.limits.MaxFileSize),
+		}
+	}
+	w.totalSize += header.Size
+	if w.totalSize > w.limits.MaxTotalSize {
+		return &BundleLimitError{
+			Entry:  header.Name,
+			Reason: fmt.Sprintf("total size of files exceeds the limit of %d bytes", w.limits.MaxTotalSize),
+		}
+	}
+
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func decompress(r io.Reader, c compression) (io.ReadCloser, error) {
+	switch c {
+	case compressionGzip:
+		return gzip.NewReader(r)
+	case compressionZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
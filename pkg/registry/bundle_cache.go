@@ -0,0 +1,161 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/multierr"
+	"golang.org/x/sync/singleflight"
+)
+
+// BundleCache is an on-disk cache of the bundle tarballs of OCI artifacts, keyed by manifest digest. It is shared by
+// all clients configured with it via ClientOptBundleCache, so that each bundle is only pulled once per node, and is
+// used when the registry is unreachable. Bundles that no reference resolves to anymore are evicted whenever a bundle
+// is stored. It is safe for concurrent use.
+type BundleCache struct {
+	dir string
+
+	// pulls collapses concurrent pulls of the same reference.
+	pulls singleflight.Group
+}
+
+// NewBundleCache returns a BundleCache storing bundles in dir, which is created if it does not exist.
+func NewBundleCache(dir string) (*BundleCache, error) {
+	for _, d := range []string{filepath.Join(dir, "bundles"), filepath.Join(dir, "refs")} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create OCI bundle cache directory: %w", err)
+		}
+	}
+
+	return &BundleCache{dir: dir}, nil
+}
+
+// openBundle opens the cached bundle tarball of the manifest with the digest dgst.
+func (c *BundleCache) openBundle(dgst digest.Digest) (*os.File, error) {
+	if err := dgst.Validate(); err != nil {
+		return nil, err
+	}
+	return os.Open(c.bundlePath(dgst))
+}
+
+// storeBundle stores the bundle tarball read from r as the bundle of the manifest with the digest dgst.
+func (c *BundleCache) storeBundle(dgst digest.Digest, r io.Reader) error {
+	if err := dgst.Validate(); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.bundlePath(dgst), r); err != nil {
+		return fmt.Errorf("failed to cache bundle %s: %w", dgst, err)
+	}
+	return nil
+}
+
+// lookupRef returns the descriptor the reference was last resolved to, if its bundle is cached.
+func (c *BundleCache) lookupRef(ref string) (ocispecv1.Descriptor, bool) {
+	data, err := os.ReadFile(c.refPath(ref))
+	if err != nil {
+		return ocispecv1.Descriptor{}, false
+	}
+
+	var desc ocispecv1.Descriptor
+	if err := json.Unmarshal(data, &desc); err != nil || desc.Digest.Validate() != nil {
+		return ocispecv1.Descriptor{}, false
+	}
+
+	if _, err := os.Stat(c.bundlePath(desc.Digest)); err != nil {
+		return ocispecv1.Descriptor{}, false
+	}
+
+	return desc, true
+}
+
+// evictUnreferenced removes the cached bundles that no reference was last resolved to, except the bundle of the
+// manifest with the digest keep.
+func (c *BundleCache) evictUnreferenced(keep digest.Digest) error {
+	refs, err := filepath.Glob(filepath.Join(c.dir, "refs", "*.json"))
+	if err != nil {
+		return err
+	}
+	referenced := map[string]bool{c.bundlePath(keep): true}
+	for _, ref := range refs {
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return fmt.Errorf("failed to read cached reference: %w", err)
+		}
+		var desc ocispecv1.Descriptor
+		if err := json.Unmarshal(data, &desc); err != nil || desc.Digest.Validate() != nil {
+			continue
+		}
+		referenced[c.bundlePath(desc.Digest)] = true
+	}
+
+	bundles, err := filepath.Glob(filepath.Join(c.dir, "bundles", "*.tar"))
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, bundle := range bundles {
+		if referenced[bundle] {
+			continue
+		}
+		if err := os.Remove(bundle); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = multierr.Append(errs, fmt.Errorf("failed to evict cached bundle: %w", err))
+		}
+	}
+	return errs
+}
+
+// storeRef records the descriptor the reference has been resolved to.
+func (c *BundleCache) storeRef(ref string, desc ocispecv1.Descriptor) error {
+	data, err := json.Marshal(desc)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(c.refPath(ref), bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to cache resolved reference %s: %w", ref, err)
+	}
+	return nil
+}
+
+func (c *BundleCache) bundlePath(dgst digest.Digest) string {
+	return filepath.Join(c.dir, "bundles", dgst.Algorithm().String()+"-"+dgst.Encoded()+".tar")
+}
+
+// refPath returns the path of the file recording the descriptor the reference was last resolved to. References are
+// hashed as they are not valid file names.
+func (c *BundleCache) refPath(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return filepath.Join(c.dir, "refs", hex.EncodeToString(sum[:])+".json")
+}
+
+// writeFileAtomic writes the data read from r to the file at path via a temporary file, so that readers never see
+// partially written files.
+func writeFileAtomic(path string, r io.Reader) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return multierr.Combine(err, f.Close())
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
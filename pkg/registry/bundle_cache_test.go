@@ -0,0 +1,367 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/testregistry"
+)
+
+func TestClient_Pull_BundleCache(t *testing.T) {
+	ctx := context.Background()
+
+	reg := testregistry.New(t)
+	layer := reg.PushBlob(
+		t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, map[string][]byte{"ca.crt": []byte("a")}),
+	)
+	manifest := reg.PushArtifact(t, "bundle", "v1", nil, layer)
+	ref := reg.Host + "/bundle:v1"
+
+	cache, err := NewBundleCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(ClientOptCAFile(reg.CAFile), ClientOptBundleCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Resolve(ctx, ref); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent pulls are collapsed and all read the cached bundle.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			r, closeFn, err := c.Pull(ctx, ref)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { _ = closeFn() }()
+			if got := string(testregistry.ReadTarball(t, r)["ca.crt"]); got != "a" {
+				t.Errorf("expected %q but got %q", "a", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The cached bundle is used once the registry is unreachable.
+	reg.Close()
+
+	desc, err := c.Resolve(ctx, ref)
+	if err != nil {
+		t.Fatalf("expected cached descriptor but got error: %v", err)
+	}
+	if desc.Digest != manifest.Digest {
+		t.Fatalf("expected cached digest %s but got %s", manifest.Digest, desc.Digest)
+	}
+
+	r, closeFn, err := c.Pull(ctx, reg.Host+"/bundle@"+desc.Digest.String())
+	if err != nil {
+		t.Fatalf("expected cached bundle but got error: %v", err)
+	}
+	defer func() { _ = closeFn() }()
+	if got := string(testregistry.ReadTarball(t, r)["ca.crt"]); got != "a" {
+		t.Errorf("expected %q but got %q", "a", got)
+	}
+}
+
+func TestClient_Pull_BundleCacheCancelledCaller(t *testing.T) {
+	reg := testregistry.New(t)
+	layer := reg.PushBlob(
+		t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, map[string][]byte{"ca.crt": []byte("a")}),
+	)
+	reg.PushArtifact(t, "bundle", "v1", nil, layer)
+	ref := reg.Host + "/bundle:v1"
+
+	cache, err := NewBundleCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(ClientOptCAFile(reg.CAFile), ClientOptBundleCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, release := reg.HoldBlobs(t)
+
+	// The first caller starts the shared pull and is cancelled while the pull is in progress.
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error, 1)
+	go func() {
+		_, _, err := c.Pull(cancelledCtx, ref)
+		cancelledErr <- err
+	}()
+	select {
+	case <-held:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the pull to start")
+	}
+
+	type result struct {
+		files map[string][]byte
+		err   error
+	}
+	waiting := make(chan result, 1)
+	go func() {
+		r, closeFn, err := c.Pull(context.Background(), ref)
+		if err != nil {
+			waiting <- result{err: err}
+			return
+		}
+		defer func() { _ = closeFn() }()
+		waiting <- result{files: testregistry.ReadTarball(t, r)}
+	}()
+	// Give the second caller time to join the shared pull.
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-cancelledErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the cancelled caller to fail with %v but got %v", context.Canceled, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the cancelled caller to return")
+	}
+
+	release()
+	select {
+	case res := <-waiting:
+		if res.err != nil {
+			t.Fatalf("expected the shared pull to succeed for the waiting caller but got %v", res.err)
+		}
+		if got := string(res.files["ca.crt"]); got != "a" {
+			t.Errorf("expected %q but got %q", "a", got)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the shared pull")
+	}
+}
+
+func TestClient_Pull_BundleLimits(t *testing.T) {
+	limits := BundleLimits{MaxFileSize: 1 << 10, MaxTotalSize: 2 << 10, MaxEntries: 3}
+
+	tests := []struct {
+		name    string
+		files   map[string][]byte
+		wantErr bool
+	}{{
+		name:  "within limits",
+		files: map[string][]byte{"a.crt": bytes.Repeat([]byte("a"), 1<<10), "b.crt": []byte("b")},
+	}, {
+		name:    "file too large",
+		files:   map[string][]byte{"a.crt": bytes.Repeat([]byte("a"), 1<<10+1)},
+		wantErr: true,
+	}, {
+		name: "total too large",
+		files: map[string][]byte{
+			"a.crt": bytes.Repeat([]byte("a"), 1<<10),
+			"b.crt": bytes.Repeat([]byte("b"), 1<<10),
+			"c.crt": []byte("c"),
+		},
+		wantErr: true,
+	}, {
+		name:    "too many entries",
+		files:   map[string][]byte{"a.crt": nil, "b.crt": nil, "c.crt": nil, "d.crt": nil},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// Layers are gzipped, so that the limits apply to the decompressed files.
+			var layerData bytes.Buffer
+			zw := gzip.NewWriter(&layerData)
+			if _, err := zw.Write(testregistry.Tarball(t, tt.files)); err != nil {
+				t.Fatal(err)
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reg := testregistry.New(t)
+			layer := reg.PushBlob(t, "bundle", ocispecv1.MediaTypeImageLayerGzip, layerData.Bytes())
+			reg.PushArtifact(t, "bundle", "v1", nil, layer)
+
+			cacheDir := t.TempDir()
+			cache, err := NewBundleCache(cacheDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := NewClient(ClientOptCAFile(reg.CAFile), ClientOptBundleCache(cache), ClientOptBundleLimits(limits))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, closeFn, err := c.Pull(ctx, reg.Host+"/bundle:v1")
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = closeFn() }()
+				if got := testregistry.ReadTarball(t, r); len(got) != len(tt.files) {
+					t.Errorf("expected %d files but got %d", len(tt.files), len(got))
+				}
+				return
+			}
+
+			var limitErr *BundleLimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected a *BundleLimitError but got %v", err)
+			}
+			bundles, err := os.ReadDir(filepath.Join(cacheDir, "bundles"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bundles) != 0 {
+				t.Errorf("expected no bundles to be cached but got %d", len(bundles))
+			}
+		})
+	}
+}
+
+func TestClient_Resolve_BundleCacheFallback(t *testing.T) {
+	tests := []struct {
+		status       int
+		wantFallback bool
+	}{
+		{status: http.StatusServiceUnavailable, wantFallback: true},
+		{status: http.StatusInternalServerError, wantFallback: true},
+		{status: http.StatusUnauthorized},
+		{status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			ctx := context.Background()
+
+			reg := testregistry.New(t)
+			layer := reg.PushBlob(
+				t, "bundle", ocispecv1.MediaTypeImageLayer,
+				testregistry.Tarball(t, map[string][]byte{"ca.crt": []byte("a")}),
+			)
+			manifest := reg.PushArtifact(t, "bundle", "v1", nil, layer)
+			ref := reg.Host + "/bundle:v1"
+
+			cache, err := NewBundleCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := NewClient(ClientOptCAFile(reg.CAFile), ClientOptBundleCache(cache))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Resolve(ctx, ref); err != nil {
+				t.Fatal(err)
+			}
+			r, closeFn, err := c.Pull(ctx, ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testregistry.ReadTarball(t, r)
+			if err := closeFn(); err != nil {
+				t.Fatal(err)
+			}
+
+			reg.RespondWith(tt.status)
+
+			desc, err := c.Resolve(ctx, ref)
+			if !tt.wantFallback {
+				if err == nil {
+					t.Errorf("expected an error instead of the cached descriptor %s", desc.Digest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected cached descriptor but got error: %v", err)
+			}
+			if desc.Digest != manifest.Digest {
+				t.Errorf("expected cached digest %s but got %s", manifest.Digest, desc.Digest)
+			}
+		})
+	}
+}
+
+func TestClient_Pull_EvictsUnreferencedBundles(t *testing.T) {
+	ctx := context.Background()
+
+	reg := testregistry.New(t)
+	cacheDir := t.TempDir()
+	cache, err := NewBundleCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(ClientOptCAFile(reg.CAFile), ClientOptBundleCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	push := func(tag, data string) {
+		layer := reg.PushBlob(
+			t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, map[string][]byte{"ca.crt": []byte(data)}),
+		)
+		reg.PushArtifact(t, "bundle", tag, nil, layer)
+	}
+	pull := func(tag string) ocispecv1.Descriptor {
+		desc, err := c.Resolve(ctx, reg.Host+"/bundle:"+tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, closeFn, err := c.Pull(ctx, reg.Host+"/bundle@"+desc.Digest.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := closeFn(); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+
+	push("v1", "a")
+	push("v2", "b")
+	v1 := pull("v1")
+	v2 := pull("v2")
+
+	// Moving the v1 tag evicts the bundle it was resolved to before, but not the bundle v2 still resolves to.
+	push("v1", "c")
+	movedV1 := pull("v1")
+
+	bundles, err := os.ReadDir(filepath.Join(cacheDir, "bundles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, bundle := range bundles {
+		got = append(got, bundle.Name())
+	}
+	want := []string{
+		filepath.Base(cache.bundlePath(movedV1.Digest)),
+		filepath.Base(cache.bundlePath(v2.Digest)),
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected bundles %q after evicting %s but got %q", want, v1.Digest, got)
+	}
+}
@@ -6,23 +6,42 @@ package registry
 import (
 	"archive/tar"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
+	"time"
 
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/remotes"
-	"github.com/klauspost/compress/gzip"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/containerd/containerd/remotes/docker/config"
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"github.com/go-logr/logr"
+	"github.com/opencontainers/go-digest"
 	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
-	"go.uber.org/multierr"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"oras.land/oras-go/pkg/auth"
 	dockerauth "oras.land/oras-go/pkg/auth/docker"
 	"oras.land/oras-go/pkg/content"
 	"oras.land/oras-go/pkg/oras"
+	"oras.land/oras-go/pkg/registry"
 	registryauth "oras.land/oras-go/pkg/registry/remote/auth"
 )
 
-const userAgent = "csi-driver-trusted-ca/v1alpha1"
+const (
+	userAgent = "csi-driver-trusted-ca/v1alpha1"
+
+	// sharedPullTimeout bounds pulls shared by concurrent callers via the bundle cache, which are not cancelled with
+	// the context of any caller.
+	sharedPullTimeout = 5 * time.Minute
+)
 
 type (
 	// Client works with OCI-compliant registries.
@@ -35,6 +54,24 @@ type (
 		authorizer         auth.Client
 		registryAuthorizer *registryauth.Client
 		resolver           remotes.Resolver
+		// credentials returns the credentials for a registry host, defaults to the credentials from the docker config
+		credentials CredentialFunc
+		// path to a PEM file of CA certificates trusted in addition to the system CA certificates when verifying
+		// registry TLS certificates
+		caFile string
+		// registry hosts, as host[:port], that are accessed via TLS without verifying their certificates
+		insecureHosts sets.Set[string]
+		// registry hosts, as host[:port], that are accessed via plain HTTP
+		plainHTTPHosts sets.Set[string]
+		// bundleCache caches pulled bundles on disk, may be nil
+		bundleCache *BundleCache
+		// path to a directory of containerd registry host configurations, e.g. /etc/containerd/certs.d, used to pull
+		// from mirrors configured via hosts.toml files
+		hostsDir string
+		// bundleLimits limit the size of pulled bundles
+		bundleLimits BundleLimits
+		// log is used to report falling back to cached bundles
+		log logr.Logger
 	}
 
 	// ClientOption allows specifying various settings configurable by the user for overriding the defaults
@@ -45,7 +82,9 @@ type (
 // NewClient returns a new registry client with config.
 func NewClient(options ...ClientOption) (*Client, error) {
 	client := &Client{
-		out: io.Discard,
+		out:          io.Discard,
+		bundleLimits: DefaultBundleLimits,
+		log:          logr.Discard(),
 	}
 	for _, option := range options {
 		option(client)
@@ -57,11 +96,17 @@ func NewClient(options ...ClientOption) (*Client, error) {
 		}
 		client.authorizer = authClient
 	}
+	if client.credentials == nil {
+		client.credentials = func(host string) (string, string, error) {
+			dockerClient, ok := client.authorizer.(*dockerauth.Client)
+			if !ok {
+				return "", "", errors.New("unable to obtain docker client")
+			}
+			return dockerClient.Credential(host)
+		}
+	}
 	if client.resolver == nil {
-		headers := http.Header{}
-		headers.Set("User-Agent", userAgent)
-		opts := []auth.ResolverOption{auth.WithResolverHeaders(headers)}
-		resolver, err := client.authorizer.ResolverWithOpts(opts...)
+		resolver, err := client.newResolver()
 		if err != nil {
 			return nil, err
 		}
@@ -80,14 +125,7 @@ func NewClient(options ...ClientOption) (*Client, error) {
 			},
 			Cache: cache,
 			Credential: func(ctx context.Context, reg string) (registryauth.Credential, error) {
-				dockerClient, ok := client.authorizer.(*dockerauth.Client)
-				if !ok {
-					return registryauth.EmptyCredential, errors.New(
-						"unable to obtain docker client",
-					)
-				}
-
-				username, password, err := dockerClient.Credential(reg)
+				username, password, err := client.credentials(reg)
 				if err != nil {
 					return registryauth.EmptyCredential, errors.New(
 						"unable to retrieve credentials",
@@ -132,6 +170,13 @@ func ClientOptWriter(out io.Writer) ClientOption {
 	}
 }
 
+// ClientOptLogger returns a function that sets the logger on a client options set.
+func ClientOptLogger(log logr.Logger) ClientOption {
+	return func(client *Client) {
+		client.log = log
+	}
+}
+
 // ClientOptCredentialsFile returns a function that sets the credentialsFile setting on a client options set.
 func ClientOptCredentialsFile(credentialsFile string) ClientOption {
 	return func(client *Client) {
@@ -139,13 +184,175 @@ func ClientOptCredentialsFile(credentialsFile string) ClientOption {
 	}
 }
 
-// ClientOptEnableCache returns a function that sets the enableCache setting on a client options set.
+// ClientOptCredentials returns a function that sets the function used to look up registry credentials on a client
+// options set. If not set, credentials are read from the docker config file.
+func ClientOptCredentials(credentials CredentialFunc) ClientOption {
+	return func(client *Client) {
+		client.credentials = credentials
+	}
+}
+
+// ClientOptCAFile returns a function that sets the caFile setting on a client options set. CA certificates in the file
+// are trusted in addition to the system CA certificates when verifying registry TLS certificates.
+func ClientOptCAFile(caFile string) ClientOption {
+	return func(client *Client) {
+		client.caFile = caFile
+	}
+}
+
+// ClientOptInsecureHosts returns a function that sets the registry hosts, as host[:port], whose TLS certificates are
+// not verified on a client options set.
+func ClientOptInsecureHosts(hosts ...string) ClientOption {
+	return func(client *Client) {
+		client.insecureHosts = sets.New(hosts...)
+	}
+}
+
+// ClientOptPlainHTTPHosts returns a function that sets the registry hosts, as host[:port], that are accessed via plain
+// HTTP on a client options set.
+func ClientOptPlainHTTPHosts(hosts ...string) ClientOption {
+	return func(client *Client) {
+		client.plainHTTPHosts = sets.New(hosts...)
+	}
+}
+
+// ClientOptBundleCache returns a function that sets the cache used to store pulled bundles on a client options set.
+func ClientOptBundleCache(cache *BundleCache) ClientOption {
+	return func(client *Client) {
+		client.bundleCache = cache
+	}
+}
+
+// ClientOptHostsDir returns a function that sets the directory of registry host configurations on a client options
+// set. The directory has the same layout and semantics as containerd's `config_path`: for each registry host, an
+// optional `<host>/hosts.toml` file lists the endpoints to pull from, e.g. mirrors, which are tried in order before
+// falling back to the upstream registry, and a `_default/hosts.toml` file applies to hosts without their own file.
+func ClientOptHostsDir(dir string) ClientOption {
+	return func(client *Client) {
+		client.hostsDir = dir
+	}
+}
+
+// ClientOptBundleLimits returns a function that sets the limits of pulled bundles on a client options set. Bundles
+// exceeding the limits are rejected with a *BundleLimitError before they are stored in the bundle cache.
+func ClientOptBundleLimits(limits BundleLimits) ClientOption {
+	return func(client *Client) {
+		client.bundleLimits = limits
+	}
+}
+
+// ClientOptResolver returns a function that sets the resolver on a client options set. Setting a resolver overrides
+// the TLS and plain HTTP settings.
 func ClientOptResolver(resolver remotes.Resolver) ClientOption {
 	return func(client *Client) {
 		client.resolver = resolver
 	}
 }
 
+// newResolver returns a resolver that verifies registry TLS certificates unless the registry host is configured as
+// insecure, and uses plain HTTP for registry hosts configured as such. If a hosts directory is configured, registry
+// hosts are configured from it.
+func (c *Client) newResolver() (remotes.Resolver, error) {
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load system CA certificates: %w", err)
+	}
+	if c.caFile != "" {
+		caPEM, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read registry CA file: %w", err)
+		}
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no CA certificates found in registry CA file %q", c.caFile)
+		}
+	}
+
+	headers := http.Header{}
+	headers.Set("User-Agent", userAgent)
+
+	secureTLSConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	insecureTLSConfig := &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec // Only used for registries explicitly configured as insecure.
+	}
+
+	if c.hostsDir != "" {
+		return docker.NewResolver(docker.ResolverOptions{
+			Headers: headers,
+			Hosts:   c.hostsFromDir(secureTLSConfig, insecureTLSConfig, headers),
+		}), nil
+	}
+
+	secureClient := newHTTPClient(secureTLSConfig)
+	insecureClient := newHTTPClient(insecureTLSConfig)
+
+	secureAuthorizer := docker.NewDockerAuthorizer(
+		docker.WithAuthClient(secureClient),
+		docker.WithAuthHeader(headers),
+		docker.WithAuthCreds(c.credentials),
+	)
+	insecureAuthorizer := docker.NewDockerAuthorizer(
+		docker.WithAuthClient(insecureClient),
+		docker.WithAuthHeader(headers),
+		docker.WithAuthCreds(c.credentials),
+	)
+
+	plainHTTP := func(host string) (bool, error) {
+		return c.plainHTTPHosts.Has(host), nil
+	}
+
+	return docker.NewResolver(docker.ResolverOptions{
+		Headers: headers,
+		Hosts: func(host string) ([]docker.RegistryHost, error) {
+			client, authorizer := secureClient, secureAuthorizer
+			if c.insecureHosts.Has(host) {
+				client, authorizer = insecureClient, insecureAuthorizer
+			}
+			return docker.ConfigureDefaultRegistries(
+				docker.WithClient(client),
+				docker.WithAuthorizer(authorizer),
+				docker.WithPlainHTTP(plainHTTP),
+			)(host)
+		},
+	}), nil
+}
+
+// hostsFromDir returns the registry hosts configured via hosts.toml files in the hosts directory, using containerd's
+// semantics: the endpoints listed in a host's hosts.toml are tried in order, followed by the upstream registry, and the
+// CA certificates, client certificates and TLS verification configured for an endpoint apply to that endpoint only.
+// Endpoints of an insecure registry host are not verified unless they configure `skip_verify`, and plain HTTP is used
+// for the upstream registry of a plain HTTP registry host unless its hosts.toml configures the upstream via `server`.
+func (c *Client) hostsFromDir(
+	secureTLSConfig, insecureTLSConfig *tls.Config,
+	headers http.Header,
+) docker.RegistryHosts {
+	return func(host string) ([]docker.RegistryHost, error) {
+		opts := config.HostOptions{
+			HostDir:        config.HostDirFromRoot(c.hostsDir),
+			Credentials:    c.credentials,
+			DefaultTLS:     secureTLSConfig,
+			DefaultScheme:  "https",
+			AuthorizerOpts: []docker.AuthorizerOpt{docker.WithAuthHeader(headers)},
+		}
+		if c.insecureHosts.Has(host) {
+			opts.DefaultTLS = insecureTLSConfig
+		}
+		if c.plainHTTPHosts.Has(host) {
+			opts.DefaultScheme = "http"
+		}
+		// The context is only used for logging.
+		return config.ConfigureHosts(context.Background(), opts)(host)
+	}
+}
+
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}
+}
+
 type (
 	// LoginOption allows specifying various settings on login.
 	LoginOption func(*loginOperation)
@@ -215,7 +422,79 @@ func (c *Client) Logout(ctx context.Context, host string, opts ...LogoutOption)
 	return nil
 }
 
-// Pull downloads a chart from a registry.
+// Resolve resolves the reference to the descriptor of its manifest.
+//
+// If the client has a bundle cache and the registry cannot be reached, the descriptor the reference was last resolved
+// to is returned if its bundle is cached. The cache is shared by all clients, so it is only used if the registry cannot
+// be reached at all or fails with a server error, never if the registry rejects the request, e.g. because the client's
+// credentials are missing or have been revoked.
+func (c *Client) Resolve(ctx context.Context, ref string) (ocispecv1.Descriptor, error) {
+	parsedRef, err := parseReference(ref)
+	if err != nil {
+		return ocispecv1.Descriptor{}, err
+	}
+
+	_, desc, err := c.resolver.Resolve(ctx, parsedRef.String())
+	if err != nil {
+		err = fmt.Errorf("failed to resolve %s: %w", parsedRef, err)
+		if c.bundleCache == nil || !isUnreachable(err) {
+			return ocispecv1.Descriptor{}, err
+		}
+		cachedDesc, ok := c.bundleCache.lookupRef(parsedRef.String())
+		if !ok {
+			return ocispecv1.Descriptor{}, err
+		}
+		c.log.Error(err, "Registry unreachable, using cached bundle",
+			"ref", parsedRef.String(), "digest", cachedDesc.Digest)
+		return cachedDesc, nil
+	}
+
+	if c.bundleCache != nil {
+		if err := c.bundleCache.storeRef(parsedRef.String(), desc); err != nil {
+			return ocispecv1.Descriptor{}, err
+		}
+	}
+
+	return desc, nil
+}
+
+// serverErrorStatus matches the errors containerd's resolver returns for HTTP responses with a server error status.
+var serverErrorStatus = regexp.MustCompile(`failed with status code .*: 5\d\d\b`)
+
+// isUnreachable returns true if the error is caused by the registry not being reachable, i.e. a network error, a
+// timeout or a server error, rather than the registry rejecting the request.
+func isUnreachable(err error) bool {
+	if errors.Is(err, errdefs.ErrNotFound) {
+		return false
+	}
+
+	var statusErr remoteerrors.ErrUnexpectedStatus
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+	switch {
+	case errors.As(err, &opErr), errors.As(err, &dnsErr):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+
+	return serverErrorStatus.MatchString(err.Error())
+}
+
+// Pull downloads the bundle of an artifact from a registry, as a single tarball of the files of all its layers.
+//
+// If the client has a bundle cache, bundles are read from the cache when pulled by digest and stored in the cache after
+// being pulled, and concurrent pulls of the same reference are collapsed into a single pull. A caller whose context is
+// done stops waiting for such a shared pull, without cancelling it for the other callers.
 func (c *Client) Pull(
 	ctx context.Context,
 	ref string,
@@ -225,67 +504,90 @@ func (c *Client) Pull(
 		return nil, nil, err
 	}
 
-	memoryStore := content.NewMemory()
-
-	var descriptors, layers []ocispecv1.Descriptor
-	registryStore := content.Registry{Resolver: c.resolver}
+	if c.bundleCache == nil {
+		_, bundle, err := c.fetchBundle(ctx, parsedRef)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(bundle), bundle.Close, nil
+	}
 
-	manifest, err := oras.Copy(ctx, registryStore, parsedRef.String(), memoryStore, "",
-		oras.WithPullEmptyNameAllowed(),
-		oras.WithLayerDescriptors(func(l []ocispecv1.Descriptor) {
-			layers = l
-		}))
-	if err != nil {
-		return nil, nil, err
+	if dgst, err := parsedRef.Digest(); err == nil {
+		if f, err := c.bundleCache.openBundle(dgst); err == nil {
+			return tar.NewReader(f), f.Close, nil
+		}
 	}
 
-	descriptors = append(descriptors, manifest)
-	descriptors = append(descriptors, layers...)
+	// The pull is shared by all concurrent callers, so it runs detached from the context of the caller that started it
+	// and each caller only waits for it until its own context is done.
+	pull := c.bundleCache.pulls.DoChan(parsedRef.String(), func() (interface{}, error) {
+		pullCtx, cancel := context.WithTimeout(context.Background(), sharedPullTimeout) //nolint:contextcheck // See above.
+		defer cancel()
 
-	numDescriptors := len(descriptors)
-	if numDescriptors < 1 {
-		return nil, nil, fmt.Errorf(
-			"manifest does not contain minimum number of descriptors (%d), descriptors found: %d",
-			1,
-			numDescriptors,
-		)
-	}
-	var tarballDescriptor *ocispecv1.Descriptor
-	for _, descriptor := range descriptors {
-		d := descriptor
-		if d.MediaType == ocispecv1.MediaTypeImageLayer ||
-			d.MediaType == ocispecv1.MediaTypeImageLayerGzip {
-			tarballDescriptor = &d
-			break
+		manifest, bundle, err := c.fetchBundle(pullCtx, parsedRef)
+		if err != nil {
+			return nil, err
 		}
+		defer func() { _ = bundle.Close() }()
+
+		if err := c.bundleCache.storeBundle(manifest.Digest, bundle); err != nil {
+			return nil, err
+		}
+		// Failing to evict stale bundles only delays their removal until the next pull, so it does not fail the pull.
+		if err := c.bundleCache.evictUnreferenced(manifest.Digest); err != nil {
+			c.log.Error(err, "Failed to evict unreferenced bundles from the cache")
+		}
+		return manifest.Digest, nil
+	})
+	var res singleflight.Result
+	select {
+	case res = <-pull:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
 	}
-	if tarballDescriptor == nil {
-		return nil, nil, fmt.Errorf(
-			"could not load config with one of mediatypes %q",
-			[]string{ocispecv1.MediaTypeImageLayer, ocispecv1.MediaTypeImageLayerGzip},
-		)
+	if res.Err != nil {
+		return nil, nil, res.Err
 	}
-	dataReader, err := memoryStore.Fetch(ctx, *tarballDescriptor)
+
+	f, err := c.bundleCache.openBundle(res.Val.(digest.Digest))
 	if err != nil {
-		return nil, nil, fmt.Errorf(
-			"unable to retrieve blob with digest %s: %w",
-			tarballDescriptor.Digest,
-			err,
-		)
+		return nil, nil, fmt.Errorf("failed to open cached bundle: %w", err)
 	}
-	switch tarballDescriptor.MediaType {
-	case ocispecv1.MediaTypeImageLayer:
-		r = tar.NewReader(dataReader)
-	case ocispecv1.MediaTypeImageLayerGzip:
-		gzipReader, err := gzip.NewReader(dataReader)
-		if err != nil {
-			if closeErr := dataReader.Close(); closeErr != nil {
-				err = multierr.Combine(err, closeErr)
-			}
-			return nil, nil, err
-		}
-		r = tar.NewReader(gzipReader)
+	return tar.NewReader(f), f.Close, nil
+}
+
+// fetchBundle pulls the artifact from the registry and returns the descriptor of its manifest and the uncompressed
+// bundle tarball. The bundle contains the files of all tarball layers, compressed or not, and of all single file layers
+// named by their title annotation, as pushed by `oras push <ref> <file>...`. Image indexes are followed to all the
+// manifests they list.
+func (c *Client) fetchBundle(
+	ctx context.Context,
+	parsedRef registry.Reference,
+) (manifest ocispecv1.Descriptor, bundle io.ReadCloser, err error) {
+	memoryStore := content.NewMemory()
+	registryStore := content.Registry{Resolver: c.resolver}
+
+	manifest, err = oras.Copy(ctx, registryStore, parsedRef.String(), memoryStore, "",
+		oras.WithPullEmptyNameAllowed())
+	if err != nil {
+		return ocispecv1.Descriptor{}, nil, err
+	}
+
+	layers, err := bundleLayers(ctx, memoryStore, manifest)
+	if err != nil {
+		return ocispecv1.Descriptor{}, nil, err
+	}
+	if len(layers) == 0 {
+		return ocispecv1.Descriptor{}, nil, fmt.Errorf(
+			"could not load bundle from %s: no layers with a %q annotation or one of media types %q",
+			parsedRef, ocispecv1.AnnotationTitle, sets.List(sets.KeySet(tarballMediaTypes)),
+		)
 	}
 
-	return r, dataReader.Close, nil
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeBundle(ctx, memoryStore, layers, c.bundleLimits, pw))
+	}()
+
+	return manifest, pr, nil
 }
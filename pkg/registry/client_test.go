@@ -0,0 +1,258 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/testregistry"
+)
+
+func TestClient_Pull_TLSVerification(t *testing.T) {
+	reg := testregistry.New(t)
+	files := map[string][]byte{"ca.crt": []byte("ca")}
+	layer := reg.PushBlob(t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, files))
+	reg.PushArtifact(t, "bundle", "v1", nil, layer)
+	ref := reg.Host + "/bundle:v1"
+
+	tests := []struct {
+		name    string
+		opts    []ClientOption
+		wantErr bool
+	}{{
+		name:    "untrusted certificate",
+		wantErr: true,
+	}, {
+		name: "trusted via CA file",
+		opts: []ClientOption{ClientOptCAFile(reg.CAFile)},
+	}, {
+		name: "insecure registry",
+		opts: []ClientOption{ClientOptInsecureHosts(reg.Host)},
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, closeFn, err := c.Pull(context.Background(), ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer closeFn()
+
+			if got := testregistry.ReadTarball(t, r); !reflect.DeepEqual(got, files) {
+				t.Errorf("expected %v but got %v", files, got)
+			}
+		})
+	}
+}
+
+func TestClient_Pull_Layers(t *testing.T) {
+	reg := testregistry.New(t)
+
+	titled := func(desc ocispecv1.Descriptor, title string) ocispecv1.Descriptor {
+		desc.Annotations = map[string]string{ocispecv1.AnnotationTitle: title}
+		return desc
+	}
+	tarball := testregistry.Tarball(t, map[string][]byte{"a.pem": []byte("a"), "certs/b.pem": []byte("b")})
+	var zstdTarball bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdTarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(tarball); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bundleFiles := map[string][]byte{"a.pem": []byte("a"), "certs/b.pem": []byte("b")}
+
+	tests := []struct {
+		name    string
+		push    func(t *testing.T, repo string)
+		want    map[string][]byte
+		wantErr bool
+	}{{
+		name: "oras files",
+		push: func(t *testing.T, repo string) {
+			reg.PushArtifact(t, repo, "v1", nil,
+				titled(reg.PushBlob(t, repo, ocispecv1.MediaTypeImageLayer, []byte("a")), "a.pem"),
+				titled(reg.PushBlob(t, repo, ocispecv1.MediaTypeImageLayer, []byte("b")), "b.pem"),
+			)
+		},
+		want: map[string][]byte{"a.pem": []byte("a"), "b.pem": []byte("b")},
+	}, {
+		name: "titled tarball",
+		push: func(t *testing.T, repo string) {
+			reg.PushArtifact(t, repo, "v1", nil,
+				titled(reg.PushBlob(t, repo, ocispecv1.MediaTypeImageLayer, tarball), "bundle.tar"),
+			)
+		},
+		want: bundleFiles,
+	}, {
+		name: "zstd tarball",
+		push: func(t *testing.T, repo string) {
+			reg.PushArtifact(t, repo, "v1", nil,
+				reg.PushBlob(t, repo, ocispecv1.MediaTypeImageLayerZstd, zstdTarball.Bytes()),
+			)
+		},
+		want: bundleFiles,
+	}, {
+		name: "bundle media type",
+		push: func(t *testing.T, repo string) {
+			reg.PushArtifact(t, repo, "v1", nil,
+				titled(reg.PushBlob(t, repo, MediaTypeBundle, tarball), "certificates"),
+				titled(reg.PushBlob(t, repo, "text/plain", []byte("c")), "c.pem"),
+			)
+		},
+		want: map[string][]byte{"a.pem": []byte("a"), "certs/b.pem": []byte("b"), "c.pem": []byte("c")},
+	}, {
+		name: "image index",
+		push: func(t *testing.T, repo string) {
+			reg.PushIndex(t, repo, "v1",
+				reg.PushArtifact(t, repo, "", nil,
+					reg.PushBlob(t, repo, MediaTypeBundleZstd, zstdTarball.Bytes()),
+				),
+				reg.PushArtifact(t, repo, "", nil,
+					titled(reg.PushBlob(t, repo, ocispecv1.MediaTypeImageLayer, []byte("c")), "c.pem"),
+				),
+			)
+		},
+		want: map[string][]byte{"a.pem": []byte("a"), "certs/b.pem": []byte("b"), "c.pem": []byte("c")},
+	}, {
+		name: "no bundle layers",
+		push: func(t *testing.T, repo string) {
+			reg.PushArtifact(t, repo, "v1", nil, reg.PushBlob(t, repo, "text/plain", []byte("a")))
+		},
+		wantErr: true,
+	}}
+	for i, tt := range tests {
+		tt := tt
+		repo := fmt.Sprintf("bundle-%d", i)
+		t.Run(tt.name, func(t *testing.T) {
+			tt.push(t, repo)
+
+			c, err := NewClient(ClientOptCAFile(reg.CAFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, closeFn, err := c.Pull(context.Background(), reg.Host+"/"+repo+":v1")
+			if tt.wantErr {
+				if err == nil {
+					_ = closeFn()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = closeFn() }()
+
+			if got := testregistry.ReadTarball(t, r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_Pull_HostsDir(t *testing.T) {
+	files := map[string][]byte{"ca.crt": []byte("ca")}
+	push := func(reg *testregistry.Registry) {
+		layer := reg.PushBlob(t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, files))
+		reg.PushArtifact(t, "bundle", "v1", nil, layer)
+	}
+
+	upstream := testregistry.New(t)
+	push(upstream)
+	mirror := testregistry.New(t)
+	push(mirror)
+	unreachable := testregistry.New(t)
+	unreachable.Close()
+
+	tests := []struct {
+		name      string
+		host      string
+		hostsToml string
+		wantErr   bool
+	}{{
+		name: "pulls from mirror",
+		host: unreachable.Host,
+		hostsToml: fmt.Sprintf(
+			"server = %q\n\n[host.%q]\ncapabilities = [\"pull\", \"resolve\"]\nca = %q\n",
+			"https://"+unreachable.Host, "https://"+mirror.Host, mirror.CAFile,
+		),
+	}, {
+		name: "falls back to upstream",
+		host: upstream.Host,
+		hostsToml: fmt.Sprintf(
+			"server = %q\nca = %q\n\n[host.%q]\ncapabilities = [\"pull\", \"resolve\"]\n",
+			"https://"+upstream.Host, upstream.CAFile, "https://"+unreachable.Host,
+		),
+	}, {
+		name: "mirror without resolve capability",
+		host: unreachable.Host,
+		hostsToml: fmt.Sprintf(
+			"server = %q\n\n[host.%q]\ncapabilities = [\"pull\"]\nca = %q\n",
+			"https://"+unreachable.Host, "https://"+mirror.Host, mirror.CAFile,
+		),
+		wantErr: true,
+	}, {
+		name:    "no hosts.toml",
+		host:    unreachable.Host,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			hostsDir := t.TempDir()
+			if tt.hostsToml != "" {
+				if err := os.MkdirAll(filepath.Join(hostsDir, tt.host), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(
+					filepath.Join(hostsDir, tt.host, "hosts.toml"), []byte(tt.hostsToml), 0o600,
+				); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := NewClient(ClientOptHostsDir(hostsDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, closeFn, err := c.Pull(context.Background(), tt.host+"/bundle:v1")
+			if tt.wantErr {
+				if err == nil {
+					_ = closeFn()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = closeFn() }()
+
+			if got := testregistry.ReadTarball(t, r); !reflect.DeepEqual(got, files) {
+				t.Errorf("expected %q but got %q", files, got)
+			}
+		})
+	}
+}
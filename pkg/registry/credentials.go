@@ -0,0 +1,83 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CredentialFunc returns the credentials for the registry host. A blank username with a non-blank secret is treated as
+// an identity token. Blank credentials are returned if there are no credentials for the host.
+type CredentialFunc func(host string) (username, secret string, err error)
+
+// dockerConfigJSON is the format of the `.dockerconfigjson` key of `kubernetes.io/dockerconfigjson` secrets.
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+type dockerConfigEntry struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// CredentialsFromDockerConfigJSON returns a CredentialFunc serving the credentials in a docker config JSON document,
+// as stored in `kubernetes.io/dockerconfigjson` secrets.
+func CredentialsFromDockerConfigJSON(data []byte) (CredentialFunc, error) {
+	var cfg dockerConfigJSON
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid docker config JSON: %w", err)
+	}
+
+	creds := make(map[string]dockerConfigEntry, len(cfg.Auths))
+	for key, entry := range cfg.Auths {
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for registry %q in docker config JSON: %w", key, err)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid auth for registry %q in docker config JSON", key)
+			}
+			entry.Username, entry.Password = username, password
+		}
+		creds[normalizeRegistryHost(key)] = entry
+	}
+
+	return func(host string) (string, string, error) {
+		entry, ok := creds[normalizeRegistryHost(host)]
+		if !ok {
+			return "", "", nil
+		}
+		if entry.IdentityToken != "" {
+			return "", entry.IdentityToken, nil
+		}
+		return entry.Username, entry.Password, nil
+	}, nil
+}
+
+// normalizeRegistryHost returns the host of a docker config key, which may be a URL such as
+// `https://index.docker.io/v1/`, mapping all Docker Hub hostnames to the same host.
+func normalizeRegistryHost(key string) string {
+	host := key
+	if strings.Contains(key, "://") {
+		if u, err := url.Parse(key); err == nil {
+			host = u.Host
+		}
+	}
+	host, _, _ = strings.Cut(host, "/")
+
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	default:
+		return host
+	}
+}
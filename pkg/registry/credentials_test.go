@@ -0,0 +1,55 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"testing"
+)
+
+func TestCredentialsFromDockerConfigJSON(t *testing.T) {
+	credentials, err := CredentialsFromDockerConfigJSON([]byte(`{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "aHViLXVzZXI6aHViLXBhc3N3b3Jk"},
+    "myregistry:5000": {"username": "user", "password": "password"},
+    "https://token.example.com": {"identitytoken": "token"}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host         string
+		wantUsername string
+		wantSecret   string
+	}{{
+		host:         "registry-1.docker.io",
+		wantUsername: "hub-user",
+		wantSecret:   "hub-password",
+	}, {
+		host:         "myregistry:5000",
+		wantUsername: "user",
+		wantSecret:   "password",
+	}, {
+		host:       "token.example.com",
+		wantSecret: "token",
+	}, {
+		host: "unknown.example.com",
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.host, func(t *testing.T) {
+			username, secret, err := credentials(tt.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if username != tt.wantUsername || secret != tt.wantSecret {
+				t.Errorf(
+					"expected credentials %q/%q but got %q/%q",
+					tt.wantUsername, tt.wantSecret, username, secret,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/multierr"
+	"oras.land/oras-go/pkg/content"
+	"oras.land/oras-go/pkg/oras"
+)
+
+const (
+	// CosignSignatureMediaType is the media type of cosign simple signing payloads.
+	CosignSignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// CosignSignatureAnnotation is the layer annotation holding the base64 encoded signature of the payload.
+	CosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// ErrSignatureVerificationFailed is returned, wrapped, if an artifact does not have a valid signature.
+var ErrSignatureVerificationFailed = errors.New("signature verification failed")
+
+// ParsePublicKey parses a PEM encoded PKIX public key, as written by `cosign generate-key-pair`. ECDSA, RSA and
+// Ed25519 keys are supported.
+func ParsePublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	switch pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// cosignPayload is the simple signing payload signed by cosign.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// VerifyCosignSignature verifies that the manifest described by desc in the repository of ref has a cosign signature
+// made with the private key matching pub. Signatures are looked up via cosign's `sha256-<digest>.sig` tag convention.
+// Errors caused by missing or invalid signatures wrap ErrSignatureVerificationFailed.
+func (c *Client) VerifyCosignSignature(
+	ctx context.Context,
+	ref string,
+	desc ocispecv1.Descriptor,
+	pub crypto.PublicKey,
+) error {
+	parsedRef, err := parseReference(ref)
+	if err != nil {
+		return err
+	}
+	parsedRef.Reference = fmt.Sprintf("%s-%s.sig", desc.Digest.Algorithm(), desc.Digest.Encoded())
+
+	memoryStore := content.NewMemory()
+	var layers []ocispecv1.Descriptor
+	_, err = oras.Copy(ctx, content.Registry{Resolver: c.resolver}, parsedRef.String(), memoryStore, "",
+		oras.WithPullEmptyNameAllowed(),
+		oras.WithAllowedMediaTypes([]string{CosignSignatureMediaType}),
+		oras.WithLayerDescriptors(func(l []ocispecv1.Descriptor) {
+			layers = l
+		}))
+	if err != nil {
+		return fmt.Errorf("%w: failed to fetch signatures %s: %v", ErrSignatureVerificationFailed, parsedRef, err)
+	}
+
+	var verifyErrs error
+	for _, layer := range layers {
+		if layer.MediaType != CosignSignatureMediaType {
+			continue
+		}
+		err := verifyCosignLayer(ctx, memoryStore, layer, desc, pub)
+		if err == nil {
+			return nil
+		}
+		verifyErrs = multierr.Append(verifyErrs, err)
+	}
+
+	if verifyErrs == nil {
+		return fmt.Errorf("%w: no signatures found in %s", ErrSignatureVerificationFailed, parsedRef)
+	}
+	return fmt.Errorf("%w: no valid signature found in %s: %v", ErrSignatureVerificationFailed, parsedRef, verifyErrs)
+}
+
+func verifyCosignLayer(
+	ctx context.Context,
+	store *content.Memory,
+	layer, desc ocispecv1.Descriptor,
+	pub crypto.PublicKey,
+) error {
+	encodedSig, ok := layer.Annotations[CosignSignatureAnnotation]
+	if !ok {
+		return fmt.Errorf("signature layer %s has no %q annotation", layer.Digest, CosignSignatureAnnotation)
+	}
+	sig, err := base64.StdEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return fmt.Errorf("invalid signature in layer %s: %w", layer.Digest, err)
+	}
+
+	r, err := store.Fetch(ctx, layer)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve signature payload %s: %w", layer.Digest, err)
+	}
+	defer r.Close()
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("unable to read signature payload %s: %w", layer.Digest, err)
+	}
+
+	if err := verifySignature(pub, payload, sig); err != nil {
+		return fmt.Errorf("signature in layer %s: %w", layer.Digest, err)
+	}
+
+	// Only trust the payload after its signature has been verified.
+	var p cosignPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("invalid signature payload %s: %w", layer.Digest, err)
+	}
+	if !strings.EqualFold(p.Critical.Image.DockerManifestDigest, desc.Digest.String()) {
+		return fmt.Errorf(
+			"signature payload %s is for manifest %s, not %s",
+			layer.Digest, p.Critical.Image.DockerManifestDigest, desc.Digest,
+		)
+	}
+
+	return nil
+}
+
+// verifySignature verifies the signature of the payload the same way as cosign: ECDSA and RSA (PKCS #1 v1.5)
+// signatures are over the SHA-256 digest of the payload, Ed25519 signatures over the payload itself.
+func verifySignature(pub crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return errors.New("invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("invalid RSA signature: %w", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errors.New("invalid Ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/testregistry"
+)
+
+func TestClient_VerifyCosignSignature(t *testing.T) {
+	reg := testregistry.New(t)
+	layer := reg.PushBlob(t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, map[string][]byte{"a": nil}))
+	signed := reg.PushArtifact(t, "bundle", "signed", nil, layer)
+	unsigned := reg.PushArtifact(t, "bundle", "unsigned", map[string]string{"unsigned": "true"}, layer)
+
+	key := generateTestKey(t)
+	otherKey := generateTestKey(t)
+	reg.PushCosignSignature(t, "bundle", signed, key)
+
+	c, err := NewClient(ClientOptCAFile(reg.CAFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		desc    ocispecv1.Descriptor
+		pub     crypto.PublicKey
+		wantErr bool
+	}{{
+		name: "valid signature",
+		desc: signed,
+		pub:  key.Public(),
+	}, {
+		name:    "signed with other key",
+		desc:    signed,
+		pub:     otherKey.Public(),
+		wantErr: true,
+	}, {
+		name:    "unsigned",
+		desc:    unsigned,
+		pub:     key.Public(),
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.VerifyCosignSignature(context.Background(), reg.Host+"/bundle", tt.desc, tt.pub)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr && !errors.Is(err, ErrSignatureVerificationFailed) {
+				t.Errorf("expected error to wrap ErrSignatureVerificationFailed but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key := generateTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PublicKey.Equal(pub) {
+		t.Errorf("parsed public key does not match")
+	}
+
+	if _, err := ParsePublicKey([]byte("not a key")); err == nil {
+		t.Errorf("expected error parsing invalid public key")
+	}
+}
+
+func generateTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
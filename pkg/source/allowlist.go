@@ -0,0 +1,113 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// AllowlistEntry allows pods in namespaces matching Namespace to reference sources matching Source. Both are glob
+// patterns in which `*` matches any sequence of characters.
+//
+// The reference of a source, i.e. the part before `?`, and its parameters are matched separately. A source may only
+// have the parameters listed in the parameters of the Source pattern, e.g. `oci::reg/team/*?pullSecret=team/*`, and
+// each of its values must match one of the patterns listed for the parameter. This keeps pods from adding parameters
+// that make the driver read other objects, e.g. secrets in other namespaces, to an allowed reference.
+type AllowlistEntry struct {
+	Namespace string
+	Source    string
+
+	namespaceRegexp *regexp.Regexp
+	refRegexp       *regexp.Regexp
+	paramRegexps    map[string][]*regexp.Regexp
+}
+
+// Allowlist controls which sources may be referenced by volumes in which namespaces.
+type Allowlist []AllowlistEntry
+
+// ParseAllowlist parses allowlist entries of the form `<namespace pattern>=<source pattern>`, e.g.
+// `pci=configmap::pci/*` or `team-*=oci::registry.example.com/team/*?pullSecret=team/*`.
+func ParseAllowlist(entries []string) (Allowlist, error) {
+	allowlist := make(Allowlist, 0, len(entries))
+	for _, e := range entries {
+		namespace, src, ok := strings.Cut(e, "=")
+		if !ok || namespace == "" || src == "" {
+			return nil, fmt.Errorf(
+				"invalid source allowlist entry %q, must be of the form <namespace pattern>=<source pattern>",
+				e,
+			)
+		}
+
+		ref, query, _ := strings.Cut(src, "?")
+		params, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters in source allowlist entry %q: %w", e, err)
+		}
+		paramRegexps := make(map[string][]*regexp.Regexp, len(params))
+		for name, values := range params {
+			for _, v := range values {
+				paramRegexps[name] = append(paramRegexps[name], globRegexp(v))
+			}
+		}
+
+		allowlist = append(allowlist, AllowlistEntry{
+			Namespace:       namespace,
+			Source:          src,
+			namespaceRegexp: globRegexp(namespace),
+			refRegexp:       globRegexp(ref),
+			paramRegexps:    paramRegexps,
+		})
+	}
+	return allowlist, nil
+}
+
+// Allows returns true if pods in the namespace are allowed to reference the source.
+func (a Allowlist) Allows(namespace, src string) bool {
+	ref, query, _ := strings.Cut(src, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return false
+	}
+
+	for _, e := range a {
+		if e.namespaceRegexp.MatchString(namespace) && e.refRegexp.MatchString(ref) && e.allowsParams(params) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsParams returns true if every value of every parameter matches one of the patterns of the parameter.
+func (e AllowlistEntry) allowsParams(params url.Values) bool {
+	for name, values := range params {
+		for _, v := range values {
+			if !matchesAny(e.paramRegexps[name], v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesAny(regexps []*regexp.Regexp, s string) bool {
+	for _, r := range regexps {
+		if r.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp returns a regexp matching the whole of a string against the glob pattern, where `*` matches any sequence
+// of characters.
+func globRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import "testing"
+
+func TestAllowlist_Allows(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{
+		"pci=configmap::pci/*",
+		"team-*=oci::registry.example.com/team/*?pullSecret=team/*&item=ca.crt&item=extra/*",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		namespace string
+		src       string
+		want      bool
+	}{
+		{namespace: "pci", src: "configmap::pci/ca-certs", want: true},
+		{namespace: "other", src: "configmap::pci/ca-certs", want: false},
+		{namespace: "pci", src: "configmap::kube-system/ca-certs", want: false},
+		{namespace: "pci", src: "configmap::pci/ca-certs?item=ca.crt", want: false},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1", want: true},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1?pullSecret=team/pull", want: true},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1?item=ca.crt&item=extra/b.crt", want: true},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1?pullSecret=kube-system/pull", want: false},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1?item=other.crt", want: false},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1?caBundle=kube-system/ca", want: false},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1?pullSecret=team%2Fpull", want: true},
+		{namespace: "team-a", src: "oci::registry.example.com/team/ca:v1?pullSecret=%zz", want: false},
+	}
+	for _, tt := range tests {
+		if got := allowlist.Allows(tt.namespace, tt.src); got != tt.want {
+			t.Errorf("expected %t for source %q in namespace %q but got %t", tt.want, tt.src, tt.namespace, got)
+		}
+	}
+}
+
+func TestParseAllowlist_Invalid(t *testing.T) {
+	for _, entry := range []string{
+		"",
+		"pci",
+		"=configmap::pci/*",
+		"pci=",
+		"pci=configmap::pci/*?item=%zz",
+	} {
+		if _, err := ParseAllowlist([]string{entry}); err == nil {
+			t.Errorf("expected an error for entry %q", entry)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+const (
+	certManagerClusterResourceNamespaceParam = "clusterResourceNamespace"
+
+	// defaultCertManagerClusterResourceNamespace is the namespace cert-manager reads the secrets referenced by
+	// ClusterIssuers from, unless configured otherwise via its `--cluster-resource-namespace` flag.
+	defaultCertManagerClusterResourceNamespace = "cert-manager"
+
+	// certManagerCAKey is the key of the CA certificate in secrets managed by cert-manager. It is the only key read
+	// from these secrets.
+	certManagerCAKey = "ca.crt"
+)
+
+var (
+	certManagerIssuerGVR = schema.GroupVersionResource{
+		Group:    "cert-manager.io",
+		Version:  "v1",
+		Resource: "issuers",
+	}
+	certManagerClusterIssuerGVR = schema.GroupVersionResource{
+		Group:    "cert-manager.io",
+		Version:  "v1",
+		Resource: "clusterissuers",
+	}
+)
+
+var (
+	_ WithKubernetesClient = &certManagerSource{}
+	_ WithDynamicClient    = &certManagerSource{}
+	_ Runnable             = &certManagerSource{}
+	_ Notifier             = &certManagerSource{}
+)
+
+func newCertManagerSource(cfg string) (Source, error) {
+	ref, params, err := splitConfig(cfg, certManagerClusterResourceNamespaceParam)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &certManagerSource{}
+	parts := strings.Split(ref, "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "issuer" && parts[1] != "" && parts[2] != "":
+		if len(params) > 0 {
+			return nil, fmt.Errorf(
+				"invalid certmanager source config %q, %s is only supported for clusterissuers",
+				cfg, certManagerClusterResourceNamespaceParam,
+			)
+		}
+		s.issuerGVR = certManagerIssuerGVR
+		s.issuerNamespace, s.issuerName = parts[1], parts[2]
+		s.secretNamespace = s.issuerNamespace
+	case len(parts) == 2 && parts[0] == "clusterissuer" && parts[1] != "":
+		s.issuerGVR = certManagerClusterIssuerGVR
+		s.issuerName = parts[1]
+		s.secretNamespace = defaultCertManagerClusterResourceNamespace
+		if ns := params.Get(certManagerClusterResourceNamespaceParam); ns != "" {
+			s.secretNamespace = ns
+		}
+	default:
+		return nil, fmt.Errorf(
+			"invalid certmanager source config %q, must be issuer/<namespace>/<name> or clusterissuer/<name>", cfg,
+		)
+	}
+
+	return s, nil
+}
+
+// certManagerSource returns the CA certificate of a cert-manager CA Issuer or ClusterIssuer, read from the `ca.crt`
+// key of the secret referenced by the issuer. Only the referenced secret is listed and watched, and it is watched anew
+// whenever the issuer references another secret. No other keys of the secret, e.g. `tls.key`, are read, and they are
+// removed from the secret before it is cached.
+type certManagerSource struct {
+	changeNotifier
+
+	issuerGVR       schema.GroupVersionResource
+	issuerNamespace string
+	issuerName      string
+	// secretNamespace is the namespace of the secret referenced by the issuer: the issuer's namespace for Issuers,
+	// and cert-manager's cluster resource namespace for ClusterIssuers.
+	secretNamespace string
+
+	kc kubernetes.Interface
+	dc dynamic.Interface
+
+	issuerInformerFactory dynamicinformer.DynamicSharedInformerFactory
+	issuerLister          cache.GenericLister
+	issuerSynced          cache.InformerSynced
+
+	mu sync.Mutex
+	// stopCh is closed when the source is stopped. It is nil until the source has been started.
+	stopCh <-chan struct{}
+	// secretWatch watches the secret currently referenced by the issuer. It is nil if the source has not been
+	// started or the issuer does not reference a secret.
+	secretWatch *certManagerSecretWatch
+}
+
+// certManagerSecretWatch watches a single secret referenced by the issuer.
+type certManagerSecretWatch struct {
+	name   string
+	lister corev1listers.SecretNamespaceLister
+	synced cache.InformerSynced
+	stop   chan struct{}
+}
+
+func (s *certManagerSource) InjectKubernetesClient(kc kubernetes.Interface) {
+	s.kc = kc
+}
+
+func (s *certManagerSource) InjectDynamicClient(dc dynamic.Interface) {
+	s.dc = dc
+
+	s.issuerInformerFactory = dynamicinformer.NewFilteredDynamicSharedInformerFactory(
+		dc, 0, s.issuerNamespace, func(opts *v1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", s.issuerName).String()
+		},
+	)
+	informer := s.issuerInformerFactory.ForResource(s.issuerGVR)
+	// AddEventHandler only errors if the informer has already been stopped, which cannot be the case here.
+	_, _ = informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { s.issuerChanged(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// Periodic resyncs deliver updates with unchanged objects, skip these.
+			if oldObj.(v1.Object).GetResourceVersion() != newObj.(v1.Object).GetResourceVersion() {
+				s.issuerChanged(newObj)
+			}
+		},
+		DeleteFunc: func(interface{}) { s.issuerChanged(nil) },
+	})
+	s.issuerLister = informer.Lister()
+	s.issuerSynced = informer.Informer().HasSynced
+}
+
+// Start starts watching the issuer and its secret and waits until they have been cached. Until Start has returned,
+// GetFiles reads the issuer and secret directly from the API server. Start fails if the issuer cannot be listed in
+// time, e.g. because the cert-manager CRDs are not installed.
+func (s *certManagerSource) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopCh = ctx.Done()
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.watchSecret("")
+	}()
+
+	s.issuerInformerFactory.Start(ctx.Done())
+	if err := waitForCacheSync(ctx, s.issuerGVR.GroupResource().String(), s.issuerSynced); err != nil {
+		return fmt.Errorf("failed to watch %s: %w (are the cert-manager CRDs installed?)", s, err)
+	}
+
+	// The event handler of the issuer may not have been called yet, so the secret is watched here as well. Issuers
+	// that are missing or do not reference a secret fail in GetFiles instead, and are watched until they are fixed.
+	secretName, err := s.issuerSecretName(ctx)
+	if err != nil {
+		return nil //nolint:nilerr // The source still works once the issuer is fixed.
+	}
+	if watch := s.watchSecret(secretName); watch != nil {
+		if err := waitForCacheSync(ctx, "secret "+s.secretNamespace+"/"+secretName, watch.synced); err != nil {
+			return fmt.Errorf("failed to watch secret of %s: %w", s, err)
+		}
+	}
+	return nil
+}
+
+// issuerChanged watches the secret referenced by the issuer, which is nil if the issuer has been deleted, and
+// notifies about the change.
+func (s *certManagerSource) issuerChanged(obj interface{}) {
+	var secretName string
+	if issuer, ok := obj.(*unstructured.Unstructured); ok {
+		secretName, _ = certManagerIssuerSecretName(issuer)
+	}
+	s.watchSecret(secretName)
+	s.notify()
+}
+
+// watchSecret watches the named secret instead of the secret watched so far, and returns the watch. No secret is
+// watched if name is empty or the source has not been started or has been stopped.
+func (s *certManagerSource) watchSecret(name string) *certManagerSecretWatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.secretWatch != nil && s.secretWatch.name == name {
+		return s.secretWatch
+	}
+	if s.secretWatch != nil {
+		close(s.secretWatch.stop)
+		s.secretWatch = nil
+	}
+	if name == "" || s.stopCh == nil {
+		return nil
+	}
+	select {
+	case <-s.stopCh:
+		return nil
+	default:
+	}
+
+	factory := newSingleObjectInformerFactory(s.kc, s.secretNamespace, name)
+	informer := factory.Core().V1().Secrets()
+	// SetTransform only errors if the informer has already been started, which cannot be the case here.
+	_ = informer.Informer().SetTransform(keepCertManagerCA)
+	// AddEventHandler only errors if the informer has already been stopped, which cannot be the case here.
+	_, _ = informer.Informer().AddEventHandler(changeHandler(s.notify))
+	s.secretWatch = &certManagerSecretWatch{
+		name:   name,
+		lister: informer.Lister().Secrets(s.secretNamespace),
+		synced: informer.Informer().HasSynced,
+		stop:   make(chan struct{}),
+	}
+	factory.Start(s.secretWatch.stop)
+	return s.secretWatch
+}
+
+func (s *certManagerSource) GetFiles(
+	ctx context.Context,
+	meta metadata.Metadata,
+) (map[string][]byte, error) {
+	secretName, err := s.issuerSecretName(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files from certmanager source: %w", err)
+	}
+
+	s.mu.Lock()
+	watch := s.secretWatch
+	s.mu.Unlock()
+
+	var secret *corev1.Secret
+	if watch != nil && watch.name == secretName && watch.synced() {
+		secret, err = watch.lister.Get(secretName)
+	} else {
+		secret, err = s.kc.CoreV1().Secrets(s.secretNamespace).Get(ctx, secretName, v1.GetOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s/%s of %s: %w", s.secretNamespace, secretName, s, err)
+	}
+
+	ca, ok := secret.Data[certManagerCAKey]
+	if !ok || len(ca) == 0 {
+		return nil, fmt.Errorf(
+			"secret %s/%s of %s has no %s key", s.secretNamespace, secretName, s, certManagerCAKey,
+		)
+	}
+
+	return map[string][]byte{certManagerCAKey: ca}, nil
+}
+
+// issuerSecretName returns the name of the secret referenced by the CA issuer.
+func (s *certManagerSource) issuerSecretName(ctx context.Context) (string, error) {
+	var (
+		issuer *unstructured.Unstructured
+		err    error
+	)
+	if s.issuerSynced != nil && s.issuerSynced() {
+		var obj interface{}
+		if s.issuerNamespace != "" {
+			obj, err = s.issuerLister.ByNamespace(s.issuerNamespace).Get(s.issuerName)
+		} else {
+			obj, err = s.issuerLister.Get(s.issuerName)
+		}
+		if err == nil {
+			issuer = obj.(*unstructured.Unstructured)
+		}
+	} else {
+		issuer, err = s.dc.Resource(s.issuerGVR).Namespace(s.issuerNamespace).Get(ctx, s.issuerName, v1.GetOptions{})
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", s, err)
+	}
+
+	secretName, ok := certManagerIssuerSecretName(issuer)
+	if !ok {
+		return "", fmt.Errorf("%s is not a CA issuer", s)
+	}
+	return secretName, nil
+}
+
+// certManagerIssuerSecretName returns the name of the secret referenced by a CA issuer, or false if the issuer is not
+// a CA issuer.
+func certManagerIssuerSecretName(issuer *unstructured.Unstructured) (string, bool) {
+	secretName, found, err := unstructured.NestedString(issuer.Object, "spec", "ca", "secretName")
+	return secretName, err == nil && found && secretName != ""
+}
+
+func (s *certManagerSource) String() string {
+	if s.issuerNamespace != "" {
+		return fmt.Sprintf("issuer %s/%s", s.issuerNamespace, s.issuerName)
+	}
+	return "clusterissuer " + s.issuerName
+}
+
+// keepCertManagerCA removes everything but the CA certificate and the metadata required to identify the secret from
+// secrets before they are cached, so that private keys are never held by the driver. Annotations are removed as well,
+// as the `kubectl.kubernetes.io/last-applied-configuration` annotation can contain the whole secret.
+func keepCertManagerCA(obj interface{}) (interface{}, error) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return obj, nil
+	}
+
+	stripped := &corev1.Secret{
+		TypeMeta: secret.TypeMeta,
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:       secret.Namespace,
+			Name:            secret.Name,
+			UID:             secret.UID,
+			ResourceVersion: secret.ResourceVersion,
+		},
+		Type: secret.Type,
+	}
+	if ca, ok := secret.Data[certManagerCAKey]; ok {
+		stripped.Data = map[string][]byte{certManagerCAKey: ca}
+	}
+	return stripped, nil
+}
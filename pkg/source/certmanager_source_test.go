@@ -0,0 +1,286 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestCertManagerSource_FollowsRotation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	kc := fake.NewSimpleClientset(newTestCertManagerSecret("pki", "root-ca", "a"))
+	dc := newTestCertManagerClient(newTestCertManagerIssuer("Issuer", "pki", "corp-ca", "root-ca"))
+
+	src, err := newCertManagerSource("issuer/pki/corp-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmSrc := src.(*certManagerSource)
+	cmSrc.InjectKubernetesClient(kc)
+	cmSrc.InjectDynamicClient(dc)
+
+	// The issuer and secret are read from the API server before the source has been started.
+	files, err := src.GetFiles(ctx, metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"ca.crt": []byte("a")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q before start but got %q", want, files)
+	}
+
+	if err := cmSrc.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the secret referenced by the issuer is listed and watched.
+	for _, action := range kc.Actions() {
+		if restrictable, ok := action.(k8stesting.ListAction); ok && action.GetResource().Resource == "secrets" {
+			if fields := restrictable.GetListRestrictions().Fields.String(); fields != "metadata.name=root-ca" {
+				t.Errorf("expected only the issuer's secret to be listed but got field selector %q", fields)
+			}
+		}
+	}
+
+	// Only the CA certificate is cached.
+	cached, err := cmSrc.secretWatch.lister.Get("root-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"ca.crt": []byte("a")}; !reflect.DeepEqual(cached.Data, want) ||
+		len(cached.Annotations) > 0 {
+		t.Errorf("expected only the CA certificate to be cached but got %v", cached)
+	}
+
+	rotated := newTestCertManagerSecret("pki", "root-ca", "b")
+	rotated.ResourceVersion = "2"
+	if _, err := kc.CoreV1().Secrets("pki").Update(ctx, rotated, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notifications for the initial adds may still be pending, so wait until a notification reflects the rotation.
+	want := map[string][]byte{"ca.crt": []byte("b")}
+	for !reflect.DeepEqual(files, want) {
+		select {
+		case <-cmSrc.Changes():
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for change notification, expected %q but got %q", want, files)
+		}
+		files, err = src.GetFiles(ctx, metadata.Metadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCertManagerSource_FollowsSecretChange(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	kc := fake.NewSimpleClientset(
+		newTestCertManagerSecret("pki", "root-ca", "a"),
+		newTestCertManagerSecret("pki", "new-root-ca", "b"),
+	)
+	issuer := newTestCertManagerIssuer("Issuer", "pki", "corp-ca", "root-ca")
+	dc := newTestCertManagerClient(issuer)
+
+	src, err := newCertManagerSource("issuer/pki/corp-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmSrc := src.(*certManagerSource)
+	cmSrc.InjectKubernetesClient(kc)
+	cmSrc.InjectDynamicClient(dc)
+	if err := cmSrc.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := newTestCertManagerIssuer("Issuer", "pki", "corp-ca", "new-root-ca")
+	changed.SetResourceVersion("2")
+	_, err = dc.Resource(certManagerIssuerGVR).Namespace("pki").Update(ctx, changed, v1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]byte{"ca.crt": []byte("b")}
+	for {
+		select {
+		case <-cmSrc.Changes():
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the secret referenced by the changed issuer to be watched")
+		}
+		files, err := src.GetFiles(ctx, metadata.Metadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmSrc.mu.Lock()
+		watched := cmSrc.secretWatch != nil && cmSrc.secretWatch.name == "new-root-ca"
+		cmSrc.mu.Unlock()
+		if watched && reflect.DeepEqual(files, want) {
+			return
+		}
+	}
+}
+
+func TestCertManagerSource_StartFailsWithoutCRDs(t *testing.T) {
+	defer func(timeout time.Duration) { cacheSyncTimeout = timeout }(cacheSyncTimeout)
+	cacheSyncTimeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dc := newTestCertManagerClient()
+	dc.PrependReactor("list", "issuers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(certManagerIssuerGVR.GroupResource(), "")
+	})
+
+	src, err := newCertManagerSource("issuer/pki/corp-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(WithKubernetesClient).InjectKubernetesClient(fake.NewSimpleClientset())
+	src.(WithDynamicClient).InjectDynamicClient(dc)
+
+	if err := src.(Runnable).Start(ctx); err == nil {
+		t.Error("expected an error if the issuers cannot be listed")
+	}
+}
+
+func TestCertManagerSource_GetFiles(t *testing.T) {
+	kc := fake.NewSimpleClientset(
+		newTestCertManagerSecret("cert-manager", "cluster-ca", "cluster"),
+		newTestCertManagerSecret("custom", "cluster-ca", "custom"),
+		&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Namespace: "pki", Name: "no-ca"},
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+		},
+	)
+	dc := newTestCertManagerClient(
+		newTestCertManagerIssuer("ClusterIssuer", "", "cluster-ca", "cluster-ca"),
+		newTestCertManagerIssuer("Issuer", "pki", "no-ca", "no-ca"),
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Issuer",
+			"metadata":   map[string]interface{}{"namespace": "pki", "name": "self-signed"},
+			"spec":       map[string]interface{}{"selfSigned": map[string]interface{}{}},
+		}},
+	)
+
+	tests := []struct {
+		cfg     string
+		want    map[string][]byte
+		wantErr bool
+	}{{
+		cfg:  "clusterissuer/cluster-ca",
+		want: map[string][]byte{"ca.crt": []byte("cluster")},
+	}, {
+		cfg:  "clusterissuer/cluster-ca?clusterResourceNamespace=custom",
+		want: map[string][]byte{"ca.crt": []byte("custom")},
+	}, {
+		cfg:     "issuer/pki/no-ca",
+		wantErr: true,
+	}, {
+		cfg:     "issuer/pki/self-signed",
+		wantErr: true,
+	}, {
+		cfg:     "issuer/pki/missing",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.cfg, func(t *testing.T) {
+			src, err := newCertManagerSource(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src.(WithKubernetesClient).InjectKubernetesClient(kc)
+			src.(WithDynamicClient).InjectDynamicClient(dc)
+
+			files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error but got files %q", files)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, files)
+			}
+		})
+	}
+}
+
+func TestNewCertManagerSource_Invalid(t *testing.T) {
+	for _, cfg := range []string{
+		"",
+		"corp-ca",
+		"issuer/corp-ca",
+		"issuer/pki/",
+		"clusterissuer/",
+		"clusterissuer/pki/corp-ca",
+		"issuer/pki/corp-ca?clusterResourceNamespace=custom",
+	} {
+		if _, err := newCertManagerSource(cfg); err == nil {
+			t.Errorf("expected an error for config %q", cfg)
+		}
+	}
+}
+
+func newTestCertManagerClient(objs ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			certManagerIssuerGVR:        "IssuerList",
+			certManagerClusterIssuerGVR: "ClusterIssuerList",
+		},
+		objs...,
+	)
+}
+
+func newTestCertManagerIssuer(kind, namespace, name, secretName string) *unstructured.Unstructured {
+	issuer := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"ca": map[string]interface{}{"secretName": secretName}},
+	}}
+	issuer.SetNamespace(namespace)
+	return issuer
+}
+
+// newTestCertManagerSecret returns a CA key pair secret as created by cert-manager.
+func newTestCertManagerSecret(namespace, name, ca string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Annotations: map[string]string{"cert-manager.io/certificate-name": name},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":                []byte(ca),
+			corev1.TLSCertKey:       []byte("cert"),
+			corev1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+const (
+	clusterTrustBundleSignerNameParam    = "signerName"
+	clusterTrustBundleLabelSelectorParam = "labelSelector"
+)
+
+// clusterTrustBundleGVR is the `certificates.k8s.io` ClusterTrustBundle resource. The typed client of the Kubernetes
+// version this driver is built with does not include the ClusterTrustBundle API yet, so it is read via the dynamic
+// client.
+var clusterTrustBundleGVR = schema.GroupVersionResource{
+	Group:    "certificates.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "clustertrustbundles",
+}
+
+var (
+	_ WithDynamicClient = &clusterTrustBundleSource{}
+	_ Runnable          = &clusterTrustBundleSource{}
+	_ Notifier          = &clusterTrustBundleSource{}
+)
+
+func newClusterTrustBundleSource(cfg string) (Source, error) {
+	name, params, err := splitConfig(cfg, clusterTrustBundleSignerNameParam, clusterTrustBundleLabelSelectorParam)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &clusterTrustBundleSource{
+		name:          name,
+		signerName:    params.Get(clusterTrustBundleSignerNameParam),
+		labelSelector: labels.Everything(),
+	}
+
+	if (s.name != "" && len(params) > 0) || (s.name == "" && s.signerName == "") {
+		return nil, fmt.Errorf(
+			"invalid clustertrustbundle source config %q, must be either <name> or ?signerName=<signer name>", cfg,
+		)
+	}
+
+	if selector := params.Get(clusterTrustBundleLabelSelectorParam); selector != "" {
+		s.labelSelector, err = labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clustertrustbundle label selector: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// clusterTrustBundleSource returns the trust bundles of either the named ClusterTrustBundle, or of all
+// ClusterTrustBundles for a signer that match a label selector. Each trust bundle is returned as a file named after
+// its ClusterTrustBundle.
+type clusterTrustBundleSource struct {
+	changeNotifier
+
+	name          string
+	signerName    string
+	labelSelector labels.Selector
+
+	dc dynamic.Interface
+
+	informerFactory dynamicinformer.DynamicSharedInformerFactory
+	lister          cache.GenericLister
+	synced          cache.InformerSynced
+}
+
+func (s *clusterTrustBundleSource) InjectDynamicClient(dc dynamic.Interface) {
+	s.dc = dc
+
+	s.informerFactory = dynamicinformer.NewFilteredDynamicSharedInformerFactory(
+		dc, 0, v1.NamespaceAll, func(opts *v1.ListOptions) {
+			selectors := s.listOptions()
+			opts.FieldSelector, opts.LabelSelector = selectors.FieldSelector, selectors.LabelSelector
+		},
+	)
+	informer := s.informerFactory.ForResource(clusterTrustBundleGVR)
+	// AddEventHandler only errors if the informer has already been stopped, which cannot be the case here.
+	_, _ = informer.Informer().AddEventHandler(changeHandler(s.notify))
+	s.lister = informer.Lister()
+	s.synced = informer.Informer().HasSynced
+}
+
+// Start starts watching the ClusterTrustBundles and waits until they have been cached. Until Start has returned,
+// GetFiles reads the ClusterTrustBundles directly from the API server. Start fails if the ClusterTrustBundles cannot
+// be listed in time, e.g. because the alpha API is not enabled.
+func (s *clusterTrustBundleSource) Start(ctx context.Context) error {
+	s.informerFactory.Start(ctx.Done())
+	if err := waitForCacheSync(ctx, clusterTrustBundleGVR.GroupResource().String(), s.synced); err != nil {
+		return fmt.Errorf("failed to watch %s: %w (is the %s API enabled?)",
+			s, err, clusterTrustBundleGVR.GroupVersion())
+	}
+	return nil
+}
+
+func (s *clusterTrustBundleSource) GetFiles(
+	ctx context.Context,
+	meta metadata.Metadata,
+) (map[string][]byte, error) {
+	bundles, err := s.getClusterTrustBundles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files from clustertrustbundle source: %w", err)
+	}
+
+	files := make(map[string][]byte, len(bundles))
+	for _, b := range bundles {
+		// Field selectors are not supported by every client, e.g. fake clients, so the signer name is checked again.
+		signerName, _, _ := unstructured.NestedString(b.Object, "spec", "signerName")
+		if s.name == "" && signerName != s.signerName {
+			continue
+		}
+
+		trustBundle, found, err := unstructured.NestedString(b.Object, "spec", "trustBundle")
+		if err != nil || !found {
+			return nil, fmt.Errorf("clustertrustbundle %s has no trust bundle", b.GetName())
+		}
+		files[b.GetName()+".pem"] = []byte(trustBundle)
+	}
+	if s.name == "" && len(files) == 0 {
+		return nil, fmt.Errorf("no clustertrustbundles found for %s", s)
+	}
+
+	return files, nil
+}
+
+func (s *clusterTrustBundleSource) getClusterTrustBundles(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	if s.synced != nil && s.synced() {
+		var objs []*unstructured.Unstructured
+		if s.name != "" {
+			obj, err := s.lister.Get(s.name)
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, obj.(*unstructured.Unstructured))
+		} else {
+			list, err := s.lister.List(s.labelSelector)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range list {
+				objs = append(objs, obj.(*unstructured.Unstructured))
+			}
+		}
+		return objs, nil
+	}
+
+	client := s.dc.Resource(clusterTrustBundleGVR)
+	if s.name != "" {
+		obj, err := client.Get(ctx, s.name, v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []*unstructured.Unstructured{obj}, nil
+	}
+	list, err := client.List(ctx, s.listOptions())
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*unstructured.Unstructured, 0, len(list.Items))
+	for i := range list.Items {
+		objs = append(objs, &list.Items[i])
+	}
+	return objs, nil
+}
+
+// listOptions returns the options restricting lists and watches to the configured ClusterTrustBundles.
+func (s *clusterTrustBundleSource) listOptions() v1.ListOptions {
+	if s.name != "" {
+		return v1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", s.name).String()}
+	}
+	return v1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.signerName", s.signerName).String(),
+		LabelSelector: s.labelSelector.String(),
+	}
+}
+
+func (s *clusterTrustBundleSource) String() string {
+	if s.name != "" {
+		return "clustertrustbundle " + s.name
+	}
+	return fmt.Sprintf("clustertrustbundles of signer %s matching %q", s.signerName, s.labelSelector)
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestClusterTrustBundleSource_GetFiles(t *testing.T) {
+	objs := []runtime.Object{
+		newTestClusterTrustBundle("standalone", "", nil, "standalone"),
+		newTestClusterTrustBundle("corp:ca:a", "example.com/corp", map[string]string{"env": "prod"}, "a"),
+		newTestClusterTrustBundle("corp:ca:b", "example.com/corp", map[string]string{"env": "prod"}, "b"),
+		newTestClusterTrustBundle("corp:ca:dev", "example.com/corp", map[string]string{"env": "dev"}, "dev"),
+		newTestClusterTrustBundle("other:ca", "example.com/other", map[string]string{"env": "prod"}, "other"),
+	}
+
+	tests := []struct {
+		cfg  string
+		want map[string][]byte
+	}{{
+		cfg:  "standalone",
+		want: map[string][]byte{"standalone.pem": []byte("standalone")},
+	}, {
+		cfg: "?signerName=example.com/corp",
+		want: map[string][]byte{
+			"corp:ca:a.pem": []byte("a"), "corp:ca:b.pem": []byte("b"), "corp:ca:dev.pem": []byte("dev"),
+		},
+	}, {
+		cfg:  "?signerName=example.com/corp&labelSelector=env%3Dprod",
+		want: map[string][]byte{"corp:ca:a.pem": []byte("a"), "corp:ca:b.pem": []byte("b")},
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.cfg, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			src, err := newClusterTrustBundleSource(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src.(WithDynamicClient).InjectDynamicClient(newTestClusterTrustBundleClient(objs...))
+
+			// Files are read from the API server before the source has been started, and from the cache afterwards.
+			got, err := src.GetFiles(ctx, metadata.Metadata{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q before start but got %q", tt.want, got)
+			}
+
+			if err := src.(Runnable).Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+			got, err = src.GetFiles(ctx, metadata.Metadata{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q after start but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClusterTrustBundleSource_NotifiesChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dc := newTestClusterTrustBundleClient(newTestClusterTrustBundle("corp:ca:a", "example.com/corp", nil, "a"))
+	src, err := newClusterTrustBundleSource("?signerName=example.com/corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctbSrc := src.(*clusterTrustBundleSource)
+	ctbSrc.InjectDynamicClient(dc)
+	if err := ctbSrc.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Drain the notification for the initial add.
+	<-ctbSrc.Changes()
+
+	_, err = dc.Resource(clusterTrustBundleGVR).Create(
+		ctx, newTestClusterTrustBundle("corp:ca:b", "example.com/corp", nil, "b"), v1.CreateOptions{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ctbSrc.Changes():
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for change notification")
+	}
+
+	files, err := src.GetFiles(ctx, metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"corp:ca:a.pem": []byte("a"), "corp:ca:b.pem": []byte("b")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q but got %q", want, files)
+	}
+}
+
+func TestClusterTrustBundleSource_StartFailsWithoutAPI(t *testing.T) {
+	defer func(timeout time.Duration) { cacheSyncTimeout = timeout }(cacheSyncTimeout)
+	cacheSyncTimeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dc := newTestClusterTrustBundleClient()
+	dc.PrependReactor("list", "clustertrustbundles", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(clusterTrustBundleGVR.GroupResource(), "")
+	})
+
+	src, err := newClusterTrustBundleSource("corp-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(WithDynamicClient).InjectDynamicClient(dc)
+
+	if err := src.(Runnable).Start(ctx); err == nil {
+		t.Error("expected an error if the ClusterTrustBundles cannot be listed")
+	}
+}
+
+func TestNewClusterTrustBundleSource_Invalid(t *testing.T) {
+	for _, cfg := range []string{
+		"",
+		"name?signerName=example.com/corp",
+		"?labelSelector=env%3Dprod",
+		"?signerName=example.com/corp&labelSelector=env%3D%3Dprod%3D",
+	} {
+		if _, err := newClusterTrustBundleSource(cfg); err == nil {
+			t.Errorf("expected an error for config %q", cfg)
+		}
+	}
+}
+
+func newTestClusterTrustBundleClient(objs ...runtime.Object) *fake.FakeDynamicClient {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{clusterTrustBundleGVR: "ClusterTrustBundleList"},
+		objs...,
+	)
+}
+
+func newTestClusterTrustBundle(
+	name, signerName string,
+	labels map[string]string,
+	trustBundle string,
+) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": clusterTrustBundleGVR.GroupVersion().String(),
+		"kind":       "ClusterTrustBundle",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"trustBundle": trustBundle},
+	}}
+	if signerName != "" {
+		_ = unstructured.SetNestedField(obj.Object, signerName, "spec", "signerName")
+	}
+	obj.SetLabels(labels)
+	return obj
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+// CollisionPolicy determines how files with the same name from different sources are handled when combining sources.
+type CollisionPolicy string
+
+const (
+	// CollisionPolicyError fails retrieving files if more than one source returns a file with the same name.
+	CollisionPolicyError CollisionPolicy = "error"
+	// CollisionPolicyFirstWins keeps the file from the first source, in the order the sources were specified.
+	CollisionPolicyFirstWins CollisionPolicy = "first-wins"
+	// CollisionPolicyPrefixBySource prefixes every file name with a name derived from its source, so that file names
+	// can never collide.
+	CollisionPolicyPrefixBySource CollisionPolicy = "prefix-by-source"
+)
+
+// CollisionPolicies lists all supported collision policies.
+var CollisionPolicies = []CollisionPolicy{
+	CollisionPolicyError,
+	CollisionPolicyFirstWins,
+	CollisionPolicyPrefixBySource,
+}
+
+// ParseCollisionPolicy parses the named collision policy.
+func ParseCollisionPolicy(s string) (CollisionPolicy, error) {
+	for _, p := range CollisionPolicies {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported collision policy %q, must be one of %q", s, CollisionPolicies)
+}
+
+var (
+	_ Runnable = &compositeSource{}
+	_ Notifier = &compositeSource{}
+
+	unsafePrefixCharsRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// NewComposite returns a source that combines the files from all the given sources, resolving file name collisions
+// according to the given policy. If only a single source is given, it is returned as is.
+func NewComposite(srcs []string, restCfg *rest.Config, policy CollisionPolicy) (Source, error) {
+	return NewFactory(restCfg).NewComposite(srcs, policy)
+}
+
+// NewComposite creates a source that combines the files from all the given sources, resolving file name collisions
+// according to the given policy. If only a single source is given, it is returned as is.
+func (f *Factory) NewComposite(srcs []string, policy CollisionPolicy) (Source, error) {
+	if len(srcs) == 0 {
+		return nil, fmt.Errorf("at least one source must be specified")
+	}
+	if _, err := ParseCollisionPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+
+	if len(srcs) == 1 {
+		return f.New(srcs[0])
+	}
+
+	cs := &compositeSource{
+		names:   srcs,
+		sources: make([]Source, 0, len(srcs)),
+		policy:  policy,
+	}
+	for _, src := range srcs {
+		s, err := f.New(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create source %q: %w", src, err)
+		}
+		cs.sources = append(cs.sources, s)
+	}
+
+	return cs, nil
+}
+
+type compositeSource struct {
+	changeNotifier
+
+	names   []string
+	sources []Source
+	policy  CollisionPolicy
+}
+
+// Start starts all sources that need to be started and forwards change notifications from all sources.
+func (s *compositeSource) Start(ctx context.Context) error {
+	for i, src := range s.sources {
+		if r, ok := src.(Runnable); ok {
+			if err := r.Start(ctx); err != nil {
+				return fmt.Errorf("failed to start source %q: %w", s.names[i], err)
+			}
+		}
+		if n, ok := src.(Notifier); ok {
+			go s.forwardChanges(ctx, n)
+		}
+	}
+	return nil
+}
+
+func (s *compositeSource) forwardChanges(ctx context.Context, n Notifier) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.Changes():
+			s.notify()
+		}
+	}
+}
+
+func (s *compositeSource) GetFiles(
+	ctx context.Context,
+	meta metadata.Metadata,
+) (map[string][]byte, error) {
+	merged := map[string][]byte{}
+	origins := map[string]string{}
+
+	for i, src := range s.sources {
+		files, err := src.GetFiles(ctx, meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read files from source %q: %w", s.names[i], err)
+		}
+
+		for name, data := range files {
+			if s.policy == CollisionPolicyPrefixBySource {
+				name = sourcePrefix(s.names[i]) + "_" + name
+			}
+
+			if origin, exists := origins[name]; exists {
+				switch s.policy {
+				case CollisionPolicyFirstWins:
+					continue
+				default:
+					return nil, fmt.Errorf(
+						"file %q is returned by both source %q and source %q",
+						name, origin, s.names[i],
+					)
+				}
+			}
+
+			merged[name] = data
+			origins[name] = s.names[i]
+		}
+	}
+
+	return merged, nil
+}
+
+// sourcePrefix returns a file name prefix derived from the source, e.g. `configmap::kube-system/ca-certs` results in
+// `configmap_kube-system_ca-certs`.
+func sourcePrefix(src string) string {
+	return strings.Trim(unsafePrefixCharsRegexp.ReplaceAllString(src, "_"), "_")
+}
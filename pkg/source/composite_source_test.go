@@ -0,0 +1,63 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+type staticSource map[string][]byte
+
+func (s staticSource) GetFiles(context.Context, metadata.Metadata) (map[string][]byte, error) {
+	return s, nil
+}
+
+func TestCompositeSource_GetFiles(t *testing.T) {
+	names := []string{"configmap::kube-system/ca-certs", "secret::team/ca"}
+	sources := []Source{
+		staticSource{"a.crt": []byte("a1"), "b.crt": []byte("b1")},
+		staticSource{"b.crt": []byte("b2"), "c.crt": []byte("c2")},
+	}
+
+	tests := []struct {
+		policy  CollisionPolicy
+		want    map[string][]byte
+		wantErr bool
+	}{{
+		policy:  CollisionPolicyError,
+		wantErr: true,
+	}, {
+		policy: CollisionPolicyFirstWins,
+		want: map[string][]byte{
+			"a.crt": []byte("a1"),
+			"b.crt": []byte("b1"),
+			"c.crt": []byte("c2"),
+		},
+	}, {
+		policy: CollisionPolicyPrefixBySource,
+		want: map[string][]byte{
+			"configmap_kube-system_ca-certs_a.crt": []byte("a1"),
+			"configmap_kube-system_ca-certs_b.crt": []byte("b1"),
+			"secret_team_ca_b.crt":                 []byte("b2"),
+			"secret_team_ca_c.crt":                 []byte("c2"),
+		},
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s := &compositeSource{names: names, sources: sources, policy: tt.policy}
+			got, err := s.GetFiles(context.Background(), metadata.Metadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
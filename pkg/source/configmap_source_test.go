@@ -0,0 +1,139 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestConfigmapSource_ServesFromCacheAndNotifiesChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "ca-certs"},
+		Data:       map[string]string{"ca.crt": "a"},
+	}
+	kc := fake.NewSimpleClientset(cm)
+
+	src, err := newConfigmapSource("ns/ca-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmSrc := src.(*configmapSource)
+	cmSrc.InjectKubernetesClient(kc)
+	if err := cmSrc.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Drain the notification for the initial add.
+	<-cmSrc.Changes()
+
+	files, err := src.GetFiles(ctx, metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(files["ca.crt"]); got != "a" {
+		t.Errorf("expected %q but got %q", "a", got)
+	}
+
+	cm = cm.DeepCopy()
+	cm.ResourceVersion = "2"
+	cm.Data["ca.crt"] = "b"
+	if _, err := kc.CoreV1().ConfigMaps("ns").Update(ctx, cm, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-cmSrc.Changes():
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for change notification")
+	}
+
+	files, err = src.GetFiles(ctx, metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(files["ca.crt"]); got != "b" {
+		t.Errorf("expected %q but got %q", "b", got)
+	}
+}
+
+func TestConfigmapSource_Items(t *testing.T) {
+	kc := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "ca-certs"},
+		Data:       map[string]string{"ca.crt": "a", "intermediate.crt": "b", "README": "c"},
+		BinaryData: map[string][]byte{"ca.der": []byte("d")},
+	})
+
+	tests := []struct {
+		cfg     string
+		want    map[string][]byte
+		wantErr bool
+	}{{
+		cfg:  "ns/ca-certs?item=ca.crt&item=ca.der",
+		want: map[string][]byte{"ca.crt": []byte("a"), "ca.der": []byte("d")},
+	}, {
+		cfg:  "ns/ca-certs?item=ca.crt:corp-root.pem&item=intermediate.crt:intermediates/corp.pem",
+		want: map[string][]byte{"corp-root.pem": []byte("a"), "intermediates/corp.pem": []byte("b")},
+	}, {
+		cfg:     "ns/ca-certs?item=ca.crt&item=missing.crt",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.cfg, func(t *testing.T) {
+			src, err := newConfigmapSource(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src.(WithKubernetesClient).InjectKubernetesClient(kc)
+
+			files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error but got files %q", files)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, files)
+			}
+		})
+	}
+}
+
+func TestNewConfigmapSource_Invalid(t *testing.T) {
+	for _, cfg := range []string{
+		"",
+		"name",
+		"ns/",
+		"ns/name/key",
+		"*/name?selector=a%3Db",
+		"/?selector=a%3Db",
+		"*/?selector=a%3D%3Db%3D",
+		"ns/name?unknown=value",
+		"ns/name?item=",
+		"ns/name?item=ca.crt:",
+		"ns/name?item=ca.crt:/etc/ca.pem",
+		"ns/name?item=ca.crt:../ca.pem",
+		"ns/name?item=ca.crt:certs/../../ca.pem",
+		"ns/name?item=ca.crt:ca.pem&item=other.crt:ca.pem",
+	} {
+		if _, err := newConfigmapSource(cfg); err == nil {
+			t.Errorf("expected an error for config %q", cfg)
+		}
+	}
+}
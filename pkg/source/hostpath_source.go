@@ -0,0 +1,241 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+// HostPathOptions configure the sources reading from the node filesystem.
+type HostPathOptions struct {
+	// Root is the directory the node filesystem is mounted at, `/` if the driver runs directly on the node.
+	Root string
+	// Allowlist are the absolute paths on the node that sources may read from, including their subdirectories. If
+	// empty, sources cannot read from the node filesystem.
+	Allowlist []string
+}
+
+// WithHostPathOptions is implemented by sources that read from the node filesystem.
+type WithHostPathOptions interface {
+	InjectHostPathOptions(HostPathOptions)
+}
+
+var _ WithHostPathOptions = &hostPathSource{}
+
+// maxHostPathSymlinks is the maximum number of symlinks followed to resolve a path, as on Linux.
+const maxHostPathSymlinks = 40
+
+func newHostPathSource(cfg string) (Source, error) {
+	if !filepath.IsAbs(cfg) {
+		return nil, fmt.Errorf("invalid hostpath source config %q, must be an absolute path or glob", cfg)
+	}
+	if _, err := filepath.Match(cfg, ""); err != nil {
+		return nil, fmt.Errorf("invalid hostpath source glob %q: %w", cfg, err)
+	}
+
+	return &hostPathSource{
+		path: filepath.Clean(cfg),
+	}, nil
+}
+
+// hostPathSource returns the regular files in a directory on the node, including its subdirectories, keyed by their
+// path relative to the directory, or the regular files matching a glob, keyed by their base name.
+type hostPathSource struct {
+	path string
+
+	opts HostPathOptions
+}
+
+func (s *hostPathSource) InjectHostPathOptions(opts HostPathOptions) {
+	s.opts = opts
+}
+
+func (s *hostPathSource) GetFiles(
+	ctx context.Context,
+	meta metadata.Metadata,
+) (map[string][]byte, error) {
+	if !s.allowed(s.path) {
+		return nil, fmt.Errorf("hostpath %s is not in the hostpath allowlist", s.path)
+	}
+
+	if isGlob(s.path) {
+		return s.readGlob()
+	}
+	return s.readDir()
+}
+
+func (s *hostPathSource) readDir() (map[string][]byte, error) {
+	resolvedDir, err := s.resolve(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hostpath %s: %w", s.path, err)
+	}
+	dir := filepath.Join(s.root(), resolvedDir)
+
+	files := map[string][]byte{}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		data, ok, err := s.readFile(path)
+		if err != nil || !ok {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hostpath %s: %w", s.path, err)
+	}
+
+	return files, nil
+}
+
+func (s *hostPathSource) readGlob() (map[string][]byte, error) {
+	// Resolve symlinks in the directory relative to the root, as the glob itself would resolve them against the
+	// driver's filesystem.
+	dir, pattern := filepath.Split(s.path)
+	if !isGlob(dir) {
+		resolvedDir, err := s.resolve(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hostpath %s: %w", s.path, err)
+		}
+		dir = resolvedDir
+	}
+	matches, err := filepath.Glob(filepath.Join(s.root(), dir, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hostpath %s: %w", s.path, err)
+	}
+
+	files := make(map[string][]byte, len(matches))
+	for _, path := range matches {
+		data, ok, err := s.readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hostpath %s: %w", s.path, err)
+		}
+		if !ok {
+			continue
+		}
+
+		name := filepath.Base(path)
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("failed to read hostpath %s: more than one file named %q", s.path, name)
+		}
+		files[name] = data
+	}
+
+	return files, nil
+}
+
+// readFile reads the file at path, which includes the root. Files that are not regular files after following
+// symlinks are skipped. Symlinks must resolve to a path in the allowlist.
+func (s *hostPathSource) readFile(path string) ([]byte, bool, error) {
+	rel, err := filepath.Rel(s.root(), path)
+	if err != nil {
+		return nil, false, err
+	}
+	resolved, err := s.resolve(string(filepath.Separator) + rel)
+	if err != nil {
+		return nil, false, err
+	}
+	if !s.allowed(resolved) {
+		return nil, false, fmt.Errorf("%s resolves to %s, which is not in the hostpath allowlist", path, resolved)
+	}
+
+	resolvedPath := filepath.Join(s.root(), resolved)
+	info, err := os.Lstat(resolvedPath)
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// resolve resolves the symlinks in the absolute path on the node as the node would, returning the resolved path on the
+// node. Absolute symlink targets are resolved relative to the root rather than the driver's filesystem, and `..`
+// cannot leave the root.
+func (s *hostPathSource) resolve(path string) (string, error) {
+	resolved := string(filepath.Separator)
+	remaining := path
+	links := 0
+	for remaining != "" {
+		var elem string
+		elem, remaining, _ = strings.Cut(remaining, string(filepath.Separator))
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, elem)
+		info, err := os.Lstat(filepath.Join(s.root(), next))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxHostPathSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links resolving %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(s.root(), next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = string(filepath.Separator)
+		}
+		remaining = target + string(filepath.Separator) + remaining
+	}
+	return resolved, nil
+}
+
+// allowed returns true if the path on the node is one of the allowlisted paths or below one of them.
+func (s *hostPathSource) allowed(path string) bool {
+	for _, allowed := range s.opts.Allowlist {
+		allowed = filepath.Clean(allowed)
+		if path == allowed || strings.HasPrefix(path, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *hostPathSource) root() string {
+	if s.opts.Root == "" {
+		return "/"
+	}
+	return s.opts.Root
+}
+
+// isGlob returns true if the path contains glob metacharacters.
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestHostPathSource_GetFiles(t *testing.T) {
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"etc/pki/anchors/a.crt":              "a",
+		"etc/pki/anchors/corp/b.crt":         "b",
+		"etc/pki/anchors/README":             "readme",
+		"usr/local/share/ca-certs/c.crt":     "c",
+		"usr/local/share/ca-certs/d.pem":     "d",
+		"usr/local/share/ca-certs/sub/e.crt": "e",
+		"etc/shadow":                         "secret",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.crt", filepath.Join(root, "etc/pki/anchors/link.crt")); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := HostPathOptions{
+		Root:      root,
+		Allowlist: []string{"/etc/pki/anchors", "/usr/local/share/ca-certs/"},
+	}
+
+	tests := []struct {
+		cfg     string
+		want    map[string][]byte
+		wantErr bool
+	}{{
+		cfg: "/etc/pki/anchors",
+		want: map[string][]byte{
+			"a.crt": []byte("a"), "corp/b.crt": []byte("b"), "README": []byte("readme"), "link.crt": []byte("a"),
+		},
+	}, {
+		cfg:  "/usr/local/share/ca-certs/*.crt",
+		want: map[string][]byte{"c.crt": []byte("c")},
+	}, {
+		cfg:  "/usr/local/share/ca-certs/*",
+		want: map[string][]byte{"c.crt": []byte("c"), "d.pem": []byte("d")},
+	}, {
+		cfg:     "/etc",
+		wantErr: true,
+	}, {
+		cfg:     "/etc/pki/anchors/../../shadow",
+		wantErr: true,
+	}, {
+		cfg:     "/etc/*",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.cfg, func(t *testing.T) {
+			src, err := newHostPathSource(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src.(WithHostPathOptions).InjectHostPathOptions(opts)
+
+			got, err := src.GetFiles(context.Background(), metadata.Metadata{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error but got files %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHostPathSource_RejectsSymlinksOutOfAllowlist(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "anchors"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "shadow"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../shadow", filepath.Join(root, "anchors", "ca.crt")); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := newHostPathSource("/anchors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(WithHostPathOptions).InjectHostPathOptions(HostPathOptions{Root: root, Allowlist: []string{"/anchors"}})
+
+	if files, err := src.GetFiles(context.Background(), metadata.Metadata{}); err == nil {
+		t.Errorf("expected an error for a symlink out of the allowlist but got files %q", files)
+	}
+}
+
+func TestHostPathSource_ResolvesAbsoluteSymlinksUnderRoot(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"etc/ssl/certs", "usr/share/ca-certificates/corp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ca := []byte("a")
+	if err := os.WriteFile(filepath.Join(root, "usr/share/ca-certificates/corp/a.crt"), ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(
+		"/usr/share/ca-certificates/corp/a.crt", filepath.Join(root, "etc/ssl/certs/a.pem"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/etc/ssl/certs", "/etc/ssl/certs/*.pem"} {
+		t.Run(path, func(t *testing.T) {
+			src, err := newHostPathSource(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src.(WithHostPathOptions).InjectHostPathOptions(HostPathOptions{
+				Root:      root,
+				Allowlist: []string{"/etc/ssl/certs", "/usr/share/ca-certificates"},
+			})
+
+			files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string][]byte{"a.pem": ca}; !reflect.DeepEqual(files, want) {
+				t.Errorf("expected files %q but got %q", want, files)
+			}
+		})
+	}
+}
+
+func TestNewHostPathSource_Invalid(t *testing.T) {
+	for _, cfg := range []string{"", "etc/pki", "/etc/[pki"} {
+		if _, err := newHostPathSource(cfg); err == nil {
+			t.Errorf("expected an error for config %q", cfg)
+		}
+	}
+}
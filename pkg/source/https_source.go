@@ -0,0 +1,252 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+const (
+	httpsSHA256Param           = "sha256"
+	httpsCABundleParam         = "caBundle"
+	httpsClientCertSecretParam = "clientCertSecret"
+
+	// httpsMaxBodySize is the maximum size of a downloaded file, protecting the driver from exhausting its memory.
+	httpsMaxBodySize = 16 << 20
+
+	httpsTimeout = time.Minute
+)
+
+var _ WithKubernetesClient = &httpsSource{}
+
+func newHTTPSSource(cfg string) (Source, error) {
+	rawURL, params, err := splitConfig(cfg, httpsSHA256Param, httpsCABundleParam, httpsClientCertSecretParam)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse("https://" + rawURL)
+	if err != nil || u.Host == "" || path.Base(u.Path) == "/" || path.Base(u.Path) == "." {
+		return nil, fmt.Errorf("invalid https source config %q, must be <host>[:<port>]/<path>", cfg)
+	}
+
+	s := &httpsSource{
+		src: "https::" + rawURL,
+		url: u,
+	}
+
+	if sum := params.Get(httpsSHA256Param); sum != "" {
+		s.sha256, err = hex.DecodeString(sum)
+		if err != nil || len(s.sha256) != sha256.Size {
+			return nil, fmt.Errorf("invalid https source checksum %q, must be a hex encoded SHA-256 digest", sum)
+		}
+	}
+
+	if caBundle := params.Get(httpsCABundleParam); caBundle != "" {
+		ref, err := parseObjectKeyRef(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("invalid https source CA bundle: %w", err)
+		}
+		s.caBundle = &ref
+	}
+
+	if clientCertSecret := params.Get(httpsClientCertSecretParam); clientCertSecret != "" {
+		s.clientCertSecret, err = parseNamespacedName(clientCertSecret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid https source client certificate secret: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// httpsSource returns either a single file, named after the last segment of the URL path, or the files in a tarball
+// if the path ends in `.tar`, `.tar.gz` or `.tgz`. Conditional requests are used so that an unchanged file is not
+// downloaded again.
+type httpsSource struct {
+	// src is the source without parameters, used to record the revision.
+	src string
+	url *url.URL
+
+	// sha256 is the optional SHA-256 digest the downloaded file must match.
+	sha256 []byte
+	// caBundle is the optional reference to the PEM encoded CA certificates used to verify the server certificate
+	// instead of the system CA certificates.
+	caBundle *objectKeyRef
+	// clientCertSecret is the optional `kubernetes.io/tls` secret containing the client certificate and key used to
+	// authenticate to the server.
+	clientCertSecret types.NamespacedName
+
+	kc kubernetes.Interface
+
+	lock sync.Mutex
+	// etag and lastModified are the validators of the last downloaded file, sent with the next request so that the
+	// server can respond with 304 Not Modified if the file is unchanged.
+	etag         string
+	lastModified string
+	// revision is the SHA-256 digest of the last downloaded file.
+	revision string
+	// fetchedFiles are the files of the last downloaded file.
+	fetchedFiles map[string][]byte
+}
+
+func (s *httpsSource) InjectKubernetesClient(kc kubernetes.Interface) {
+	s.kc = kc
+}
+
+func (s *httpsSource) GetFiles(
+	ctx context.Context,
+	meta metadata.Metadata,
+) (map[string][]byte, error) {
+	client, err := s.httpClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseIdleConnections()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url.String(), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", s.url, err)
+	}
+	if s.fetchedFiles != nil {
+		if s.etag != "" {
+			req.Header.Set("If-None-Match", s.etag)
+		}
+		if s.lastModified != "" {
+			req.Header.Set("If-Modified-Since", s.lastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && s.fetchedFiles != nil:
+		metadata.RecordRevision(ctx, s.src, s.revision)
+		return s.copyFetchedFiles(), nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to download %s: unexpected status %s", s.url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpsMaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", s.url, err)
+	}
+	if len(body) > httpsMaxBodySize {
+		return nil, fmt.Errorf("failed to download %s: file exceeds %d bytes", s.url, httpsMaxBodySize)
+	}
+
+	sum := sha256.Sum256(body)
+	if s.sha256 != nil && !bytes.Equal(sum[:], s.sha256) {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected sha256 %x, got %x", s.url, s.sha256, sum)
+	}
+
+	files, err := s.extract(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.url, err)
+	}
+
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	s.revision = "sha256:" + hex.EncodeToString(sum[:])
+	s.fetchedFiles = files
+
+	metadata.RecordRevision(ctx, s.src, s.revision)
+
+	return s.copyFetchedFiles(), nil
+}
+
+// extract returns the files in the downloaded file.
+func (s *httpsSource) extract(body []byte) (map[string][]byte, error) {
+	name := path.Base(s.url.Path)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return readTarball(tar.NewReader(bytes.NewReader(body)))
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress tarball: %w", err)
+		}
+		defer gzipReader.Close()
+		return readTarball(tar.NewReader(gzipReader))
+	default:
+		return map[string][]byte{name: body}, nil
+	}
+}
+
+// copyFetchedFiles returns a copy of the last downloaded files so that callers cannot modify them. The lock must be
+// held.
+func (s *httpsSource) copyFetchedFiles() map[string][]byte {
+	files := make(map[string][]byte, len(s.fetchedFiles))
+	for name, data := range s.fetchedFiles {
+		files[name] = data
+	}
+	return files
+}
+
+// httpClient returns the client used to download the file. The CA bundle and client certificate are read for every
+// download so that rotated certificates are used without restarting the driver.
+func (s *httpsSource) httpClient(ctx context.Context) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if s.caBundle != nil {
+		caPEM, err := readObjectKey(ctx, s.kc, *s.caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read https source CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("https source CA bundle %s contains no PEM encoded certificates", s.caBundle)
+		}
+	}
+
+	if s.clientCertSecret.Name != "" {
+		secret, err := s.kc.CoreV1().Secrets(s.clientCertSecret.Namespace).Get(
+			ctx, s.clientCertSecret.Name, v1.GetOptions{},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read https source client certificate secret %s: %w",
+				s.clientCertSecret, err)
+		}
+		clientCert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate in https source secret %s: %w",
+				s.clientCertSecret, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport, Timeout: httpsTimeout}, nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/testregistry"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestHTTPSSource_ConditionalRequests(t *testing.T) {
+	var requests, downloads atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("a"))
+	}))
+	defer srv.Close()
+
+	src := newTestHTTPSSource(t, srv, "/certs/ca.pem", "")
+
+	for i := 0; i < 2; i++ {
+		ctx, revisions := metadata.WithRevisionRecorder(context.Background())
+		files, err := src.GetFiles(ctx, metadata.Metadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string][]byte{"ca.pem": []byte("a")}; !reflect.DeepEqual(files, want) {
+			t.Errorf("expected %q but got %q", want, files)
+		}
+		want := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("a")))
+		if got := revisions.Revisions()[src.src]; got != want {
+			t.Errorf("expected revision %q but got %q", want, got)
+		}
+	}
+
+	if requests.Load() != 2 || downloads.Load() != 1 {
+		t.Errorf("expected 2 requests and 1 download but got %d requests and %d downloads",
+			requests.Load(), downloads.Load())
+	}
+}
+
+func TestHTTPSSource_Tarball(t *testing.T) {
+	tarball := testregistry.Tarball(t, map[string][]byte{"a.crt": []byte("a"), "sub/b.crt": []byte("b")})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(tarball)
+	}))
+	defer srv.Close()
+
+	src := newTestHTTPSSource(t, srv, "/bundle.tar", "")
+	files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"a.crt": []byte("a"), "sub/b.crt": []byte("b")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q but got %q", want, files)
+	}
+}
+
+func TestHTTPSSource_VerifiesChecksum(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("a"))
+	}))
+	defer srv.Close()
+
+	src := newTestHTTPSSource(t, srv, "/ca.pem", fmt.Sprintf("sha256=%x", sha256.Sum256([]byte("a"))))
+	if _, err := src.GetFiles(context.Background(), metadata.Metadata{}); err != nil {
+		t.Errorf("expected matching checksum to succeed but got: %v", err)
+	}
+
+	src = newTestHTTPSSource(t, srv, "/ca.pem", fmt.Sprintf("sha256=%x", sha256.Sum256([]byte("b"))))
+	if _, err := src.GetFiles(context.Background(), metadata.Metadata{}); err == nil ||
+		!strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch error but got: %v", err)
+	}
+}
+
+func TestHTTPSSource_ClientCertificate(t *testing.T) {
+	clientCA, clientCAKey := newTestHTTPSCA(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("a"))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+
+	src := newTestHTTPSSource(t, srv, "/ca.pem", "clientCertSecret=ns/client-cert")
+	if _, err := src.GetFiles(context.Background(), metadata.Metadata{}); err == nil {
+		t.Error("expected an error without a client certificate secret")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, clientCA, key.Public(), clientCAKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = src.kc.CoreV1().Secrets("ns").Create(context.Background(), &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "client-cert"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		},
+	}, v1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"ca.pem": []byte("a")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q but got %q", want, files)
+	}
+}
+
+func TestNewHTTPSSource_Invalid(t *testing.T) {
+	for _, cfg := range []string{
+		"",
+		"example.com",
+		"example.com/",
+		"example.com/ca.pem?sha256=abc",
+		"example.com/ca.pem?caBundle=ns/name",
+		"example.com/ca.pem?clientCertSecret=name",
+		"example.com/ca.pem?unknown=value",
+	} {
+		if _, err := newHTTPSSource(cfg); err == nil {
+			t.Errorf("expected an error for config %q", cfg)
+		}
+	}
+}
+
+// newTestHTTPSSource returns a source downloading the path from the server, trusting the server's certificate via a
+// CA bundle in a configmap.
+func newTestHTTPSSource(t *testing.T, srv *httptest.Server, path, params string) *httpsSource {
+	t.Helper()
+
+	cfg := strings.TrimPrefix(srv.URL, "https://") + path + "?caBundle=configmap/ns/server-ca/ca.crt"
+	if params != "" {
+		cfg += "&" + params
+	}
+	src, err := newHTTPSSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kc := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "server-ca"},
+		Data: map[string]string{
+			"ca.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})),
+		},
+	})
+	src.(WithKubernetesClient).InjectKubernetesClient(kc)
+
+	return src.(*httpsSource)
+}
+
+func newTestHTTPSCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
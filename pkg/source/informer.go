@@ -0,0 +1,104 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// changeNotifier implements Notifier for sources that embed it. Notifications are coalesced: if a notification is
+// already pending, further notifications are dropped until it has been received.
+type changeNotifier struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (n *changeNotifier) Changes() <-chan struct{} {
+	n.once.Do(n.init)
+	return n.ch
+}
+
+func (n *changeNotifier) notify() {
+	n.once.Do(n.init)
+	select {
+	case n.ch <- struct{}{}:
+	default:
+	}
+}
+
+func (n *changeNotifier) init() {
+	n.ch = make(chan struct{}, 1)
+}
+
+// newSingleObjectInformerFactory returns an informer factory whose informers only list and watch the object with the
+// given name in the given namespace, keeping the load on the API server to a minimum.
+func newSingleObjectInformerFactory(
+	kc kubernetes.Interface,
+	namespace, name string,
+) informers.SharedInformerFactory {
+	return informers.NewSharedInformerFactoryWithOptions(
+		kc,
+		0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *v1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+}
+
+// changeHandler returns an event handler that calls notify whenever a watched object is added, changed or deleted.
+func changeHandler(notify func()) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMeta, oldOK := oldObj.(v1.Object)
+			newMeta, newOK := newObj.(v1.Object)
+			// Periodic resyncs deliver updates with unchanged objects, skip these.
+			if oldOK && newOK && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				return
+			}
+			notify()
+		},
+		DeleteFunc: func(interface{}) { notify() },
+	}
+}
+
+// startInformers starts all informers requested from the factory and waits for their caches to sync.
+func startInformers(ctx context.Context, factory informers.SharedInformerFactory) error {
+	factory.Start(ctx.Done())
+	for typ, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer cache for %v", typ)
+		}
+	}
+	return nil
+}
+
+// cacheSyncTimeout bounds how long sources watching optional APIs, e.g. custom resources or alpha APIs, wait for their
+// informers to sync. Informers never sync if the API is not served.
+var cacheSyncTimeout = time.Minute
+
+// waitForCacheSync waits until the informers have synced, for at most cacheSyncTimeout. resource describes the watched
+// resource in errors.
+func waitForCacheSync(ctx context.Context, resource string, synced ...cache.InformerSynced) error {
+	syncCtx, cancel := context.WithTimeout(ctx, cacheSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced...) {
+		if errors.Is(syncCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("timed out after %v waiting to list %s", cacheSyncTimeout, resource)
+		}
+		return fmt.Errorf("failed to sync informer cache for %s", resource)
+	}
+	return nil
+}
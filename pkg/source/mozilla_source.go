@@ -0,0 +1,146 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"embed"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/pki"
+)
+
+// mozillaSnapshots holds the embedded snapshots of the Mozilla root store, as `mozilla/<NSS version>/certdata.txt`.
+// Snapshots are added via `make update-mozilla-certdata NSS_VERSION=<version>`.
+//
+//go:embed mozilla
+var mozillaSnapshots embed.FS
+
+const mozillaLatest = "latest"
+
+func newMozillaSource(cfg string) (Source, error) {
+	return newMozillaSourceFromFS(cfg, mozillaSnapshots)
+}
+
+func newMozillaSourceFromFS(cfg string, snapshots fs.FS) (Source, error) {
+	versions, err := mozillaSnapshotVersions(snapshots)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no Mozilla root store snapshots are embedded")
+	}
+
+	v := cfg
+	if v == "" || v == mozillaLatest {
+		v = versions[len(versions)-1]
+	}
+
+	found := false
+	for _, sv := range versions {
+		if sv == v {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid mozilla source config %q, must be %q or one of the embedded versions %q",
+			cfg, mozillaLatest, versions)
+	}
+
+	return &mozillaSource{
+		version:   v,
+		snapshots: snapshots,
+	}, nil
+}
+
+// mozillaSource returns the certificates trusted for server authentication in an embedded snapshot of the Mozilla
+// root store, one file per certificate. The snapshot is parsed once on first use.
+type mozillaSource struct {
+	version   string
+	snapshots fs.FS
+
+	once  sync.Once
+	files map[string][]byte
+	err   error
+}
+
+func (s *mozillaSource) GetFiles(ctx context.Context, _ metadata.Metadata) (map[string][]byte, error) {
+	s.once.Do(func() {
+		s.files, s.err = s.readSnapshot()
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	metadata.RecordRevision(ctx, "mozilla::"+s.version, s.version)
+
+	// Return a copy so that callers cannot modify the cached files.
+	files := make(map[string][]byte, len(s.files))
+	for name, data := range s.files {
+		files[name] = data
+	}
+	return files, nil
+}
+
+func (s *mozillaSource) readSnapshot() (map[string][]byte, error) {
+	f, err := s.snapshots.Open(path.Join("mozilla", s.version, "certdata.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Mozilla root store %s: %w", s.version, err)
+	}
+	defer f.Close()
+
+	certs, err := pki.ParseCertdata(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Mozilla root store %s: %w", s.version, err)
+	}
+
+	files := make(map[string][]byte, len(certs))
+	for _, c := range certs {
+		name := unsafePrefixCharsRegexp.ReplaceAllString(c.Label, "_") + ".crt"
+		for i := 1; files[name] != nil; i++ {
+			name = fmt.Sprintf("%s_%d.crt", unsafePrefixCharsRegexp.ReplaceAllString(c.Label, "_"), i)
+		}
+		files[name] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Certificate.Raw})
+	}
+	return files, nil
+}
+
+// mozillaSnapshotVersions returns the versions of the embedded snapshots, sorted from oldest to newest.
+func mozillaSnapshotVersions(snapshots fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(snapshots, "mozilla")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Mozilla root store snapshots: %w", err)
+	}
+
+	var (
+		names    []string
+		versions = map[string]*version.Version{}
+	)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := version.ParseGeneric(e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("invalid Mozilla root store snapshot version %q: %w", e.Name(), err)
+		}
+		names = append(names, e.Name())
+		versions[e.Name()] = v
+	}
+
+	// Sort by version rather than by name, e.g. 3.100 is newer than 3.99.
+	sort.Slice(names, func(i, j int) bool {
+		return versions[names[i]].LessThan(versions[names[j]])
+	})
+	return names, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/testcertdata"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestMozillaSource_GetFiles(t *testing.T) {
+	older, _ := newTestHTTPSCA(t)
+	newer, _ := newTestHTTPSCA(t)
+	olderPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: older.Raw})
+	newerPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: newer.Raw})
+	snapshots := fstest.MapFS{
+		"mozilla/README.md":           {Data: []byte("snapshots")},
+		"mozilla/3.99/certdata.txt":   {Data: []byte(testCertdata(older, "Older Root CA"))},
+		"mozilla/3.100/certdata.txt":  {Data: []byte(testCertdata(newer, "Newer Root CA"))},
+		"mozilla/3.9/certdata.txt":    {Data: []byte(testCertdata(older, "Oldest Root CA"))},
+		"mozilla/3.100/unrelated.txt": {Data: []byte("unrelated")},
+	}
+
+	tests := []struct {
+		cfg         string
+		wantVersion string
+		want        map[string][]byte
+	}{{
+		cfg:         "",
+		wantVersion: "3.100",
+		want:        map[string][]byte{"Newer_Root_CA.crt": newerPEM},
+	}, {
+		cfg:         "latest",
+		wantVersion: "3.100",
+		want:        map[string][]byte{"Newer_Root_CA.crt": newerPEM},
+	}, {
+		cfg:         "3.99",
+		wantVersion: "3.99",
+		want:        map[string][]byte{"Older_Root_CA.crt": olderPEM},
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.cfg, func(t *testing.T) {
+			src, err := newMozillaSourceFromFS(tt.cfg, snapshots)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, revisions := metadata.WithRevisionRecorder(context.Background())
+			got, err := src.GetFiles(ctx, metadata.Metadata{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+			want := map[string]string{"mozilla::" + tt.wantVersion: tt.wantVersion}
+			if !reflect.DeepEqual(revisions.Revisions(), want) {
+				t.Errorf("expected revisions %v but got %v", want, revisions.Revisions())
+			}
+		})
+	}
+
+	if _, err := newMozillaSourceFromFS("3.98", snapshots); err == nil {
+		t.Error("expected an error for a version that is not embedded")
+	}
+	if _, err := newMozillaSourceFromFS("", fstest.MapFS{"mozilla/README.md": {}}); err == nil {
+		t.Error("expected an error when no snapshots are embedded")
+	}
+}
+
+func TestMozillaSource_Embedded(t *testing.T) {
+	versions, err := mozillaSnapshotVersions(mozillaSnapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) == 0 {
+		t.Fatal("expected at least one embedded snapshot")
+	}
+
+	for _, v := range versions {
+		v := v
+		t.Run(v, func(t *testing.T) {
+			src, err := newMozillaSource(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The Mozilla root store has well over 100 roots trusted for server authentication.
+			if len(files) < 100 {
+				t.Errorf("expected the roots of the Mozilla root store but got %d files", len(files))
+			}
+			for name, data := range files {
+				block, _ := pem.Decode(data)
+				if block == nil {
+					t.Fatalf("expected a PEM certificate in %q", name)
+				}
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					t.Fatalf("invalid certificate in %q: %v", name, err)
+				}
+				if !cert.IsCA {
+					t.Errorf("expected a CA certificate in %q", name)
+				}
+			}
+		})
+	}
+
+	if _, err := newMozillaSource(""); err != nil {
+		t.Errorf("expected the latest embedded snapshot to be used by default but got %v", err)
+	}
+}
+
+// testCertdata returns a certdata.txt file containing the certificate, trusted for server authentication.
+func testCertdata(cert *x509.Certificate, label string) string {
+	return "BEGINDATA\n" + testcertdata.Objects(cert, label, "CKT_NSS_TRUSTED_DELEGATOR", "CKT_NSS_MUST_VERIFY_TRUST")
+}
@@ -4,88 +4,276 @@
 package source
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
-	"io"
-	"net/http"
+	"sync"
 
-	"github.com/containerd/containerd/remotes/docker"
 	"github.com/distribution/distribution/v3/reference"
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/registry"
 )
 
+const (
+	ociPullSecretParam = "pullSecret"
+	ociVerifyKeyParam  = "verifyKey"
+)
+
+var (
+	_ WithRegistryOptions  = &ociSource{}
+	_ WithKubernetesClient = &ociSource{}
+	_ Runnable             = &ociSource{}
+)
+
 func newOCISource(cfg string) (Source, error) {
-	ref, err := reference.ParseNormalizedNamed(cfg)
+	rawRef, params, err := splitConfig(cfg, ociPullSecretParam, ociVerifyKeyParam)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := reference.ParseNormalizedNamed(rawRef)
 	if err != nil {
 		return nil, fmt.Errorf("invalid OCI reference: %w", err)
 	}
-	return &ociSource{
+
+	s := &ociSource{
 		ref: ref,
-	}, nil
+	}
+
+	if pullSecret := params.Get(ociPullSecretParam); pullSecret != "" {
+		s.pullSecret, err = parseNamespacedName(pullSecret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OCI pull secret: %w", err)
+		}
+	}
+
+	if verifyKey := params.Get(ociVerifyKeyParam); verifyKey != "" {
+		ref, err := parseObjectKeyRef(verifyKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OCI signature verification key: %w", err)
+		}
+		s.verifyKey = &ref
+	}
+
+	return s, nil
 }
 
 type ociSource struct {
 	ref reference.Named
+
+	registryOpts []registry.ClientOption
+
+	// pullSecret is the optional `kubernetes.io/dockerconfigjson` secret containing the registry credentials.
+	pullSecret types.NamespacedName
+	// verifyKey is the optional reference to the public key used to verify the artifact's cosign signature. If set,
+	// files are only returned from artifacts with a valid signature.
+	verifyKey *objectKeyRef
+
+	kc kubernetes.Interface
+
+	informerFactory  informers.SharedInformerFactory
+	pullSecretLister corev1listers.SecretNamespaceLister
+	pullSecretSynced cache.InformerSynced
+
+	lock sync.Mutex
+	// pulledDigest is the manifest digest of the last pulled artifact, used to skip pulling the artifact again while
+	// the reference resolves to the same digest.
+	pulledDigest digest.Digest
+	// pulledFiles are the files of the last pulled artifact.
+	pulledFiles map[string][]byte
+}
+
+func (s *ociSource) InjectRegistryOptions(opts ...registry.ClientOption) {
+	s.registryOpts = opts
+}
+
+func (s *ociSource) InjectKubernetesClient(kc kubernetes.Interface) {
+	s.kc = kc
+
+	if s.pullSecret.Name == "" {
+		return
+	}
+
+	s.informerFactory = newSingleObjectInformerFactory(kc, s.pullSecret.Namespace, s.pullSecret.Name)
+	informer := s.informerFactory.Core().V1().Secrets()
+	s.pullSecretLister = informer.Lister().Secrets(s.pullSecret.Namespace)
+	s.pullSecretSynced = informer.Informer().HasSynced
+}
+
+// Start starts watching the pull secret, if configured, so that rotated credentials are used for subsequent pulls.
+func (s *ociSource) Start(ctx context.Context) error {
+	if s.informerFactory == nil {
+		return nil
+	}
+	if err := startInformers(ctx, s.informerFactory); err != nil {
+		return fmt.Errorf("failed to watch OCI pull secret %s: %w", s.pullSecret, err)
+	}
+	return nil
 }
 
 func (s *ociSource) GetFiles(
 	ctx context.Context,
 	meta metadata.Metadata,
 ) (map[string][]byte, error) {
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: true, //nolint:gosec // Yes, this is insecure - fix later.
+	// Bundles are limited while they are pulled, so that oversized bundles are rejected before they are cached.
+	opts := append(s.registryOpts[:len(s.registryOpts):len(s.registryOpts)],
+		registry.ClientOptBundleLimits(defaultTarballLimits.bundleLimits()))
+	if s.pullSecret.Name != "" {
+		credentials, err := s.pullSecretCredentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts[:len(opts):len(opts)], registry.ClientOptCredentials(credentials))
 	}
-	transport := &http.Transport{
-		TLSClientConfig: tlsConfig,
+
+	ociClient, err := registry.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCI registry client: %w", err)
 	}
-	insecureClient := &http.Client{
-		Transport: transport,
+
+	desc, err := s.resolve(ctx, ociClient)
+	if err != nil {
+		return nil, err
 	}
 
-	resolver := docker.NewResolver(
-		docker.ResolverOptions{
-			Hosts: docker.ConfigureDefaultRegistries(docker.WithClient(insecureClient)),
-		},
-	)
+	if s.verifyKey != nil {
+		if err := s.verifySignature(ctx, ociClient, desc); err != nil {
+			return nil, err
+		}
+	}
 
-	ociClient, err := registry.NewClient(registry.ClientOptResolver(resolver))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create OCI registry client: %w", err)
+	metadata.RecordRevision(ctx, "oci::"+s.ref.String(), desc.Digest.String())
+
+	if files, ok := s.cachedFiles(desc.Digest); ok {
+		return files, nil
 	}
-	artifactReader, closeFn, err := ociClient.Pull(ctx, s.ref.String())
+
+	// Pull by digest to ensure that the artifact pulled is the one that was resolved and verified.
+	artifactReader, closeFn, err := ociClient.Pull(ctx, fmt.Sprintf("%s@%s", s.ref.Name(), desc.Digest))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read bundle from OCI registry: %w", err)
+		return nil, fmt.Errorf("failed to read bundle from OCI registry: %w", bundleLimitError(err))
 	}
 	defer func() { _ = closeFn() }()
 
-	files := map[string][]byte{}
+	files, err := readTarball(artifactReader)
+	if err != nil {
+		return nil, bundleLimitError(err)
+	}
 
-	for {
-		header, err := artifactReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to read tarball: %w", err)
-		}
+	s.cacheFiles(desc.Digest, files)
 
-		info := header.FileInfo()
-		if info.IsDir() {
-			continue
-		}
+	return files, nil
+}
 
-		var buf bytes.Buffer
-		_, err = io.Copy(&buf, artifactReader)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read tarball: %w", err)
-		}
+// resolve resolves the reference to the descriptor of the artifact's manifest. If the reference is pinned to a digest,
+// the resolved digest must match it. References with both a tag and a digest are resolved via the tag, so that the
+// pinned digest is rejected once the tag has been moved.
+func (s *ociSource) resolve(ctx context.Context, ociClient *registry.Client) (ocispecv1.Descriptor, error) {
+	resolveRef := s.ref.String()
+	if tagged, ok := s.ref.(reference.Tagged); ok {
+		resolveRef = fmt.Sprintf("%s:%s", s.ref.Name(), tagged.Tag())
+	}
+
+	desc, err := ociClient.Resolve(ctx, resolveRef)
+	if err != nil {
+		return ocispecv1.Descriptor{}, fmt.Errorf("failed to read bundle from OCI registry: %w", err)
+	}
 
-		files[header.Name] = buf.Bytes()
+	if canonical, ok := s.ref.(reference.Canonical); ok && canonical.Digest() != desc.Digest {
+		return ocispecv1.Descriptor{}, fmt.Errorf(
+			"digest mismatch for bundle %s: registry returned %s", s.ref, desc.Digest,
+		)
 	}
 
-	return files, nil
+	return desc, nil
+}
+
+// cachedFiles returns the files of the last pulled artifact if its digest matches dgst.
+func (s *ociSource) cachedFiles(dgst digest.Digest) (map[string][]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pulledDigest != dgst {
+		return nil, false
+	}
+
+	files := make(map[string][]byte, len(s.pulledFiles))
+	for name, data := range s.pulledFiles {
+		files[name] = data
+	}
+	return files, true
+}
+
+// cacheFiles stores the files of the pulled artifact with the digest dgst.
+func (s *ociSource) cacheFiles(dgst digest.Digest, files map[string][]byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pulledDigest = dgst
+	s.pulledFiles = make(map[string][]byte, len(files))
+	for name, data := range files {
+		s.pulledFiles[name] = data
+	}
+}
+
+// verifySignature verifies the cosign signature of the artifact described by desc.
+func (s *ociSource) verifySignature(
+	ctx context.Context,
+	ociClient *registry.Client,
+	desc ocispecv1.Descriptor,
+) error {
+	keyPEM, err := readObjectKey(ctx, s.kc, *s.verifyKey)
+	if err != nil {
+		return fmt.Errorf("failed to read OCI signature verification key: %w", err)
+	}
+	pub, err := registry.ParsePublicKey(keyPEM)
+	if err != nil {
+		return fmt.Errorf("invalid OCI signature verification key %s: %w", s.verifyKey, err)
+	}
+
+	if err := ociClient.VerifyCosignSignature(ctx, s.ref.String(), desc, pub); err != nil {
+		return fmt.Errorf("refusing to read bundle %s from OCI registry: %w", s.ref, err)
+	}
+
+	return nil
+}
+
+// pullSecretCredentials reads the registry credentials from the pull secret. The secret is read for every pull so that
+// rotated credentials are picked up.
+func (s *ociSource) pullSecretCredentials(ctx context.Context) (registry.CredentialFunc, error) {
+	var (
+		secret *corev1.Secret
+		err    error
+	)
+	if s.pullSecretSynced != nil && s.pullSecretSynced() {
+		secret, err = s.pullSecretLister.Get(s.pullSecret.Name)
+	} else {
+		secret, err = s.kc.CoreV1().Secrets(s.pullSecret.Namespace).Get(ctx, s.pullSecret.Name, v1.GetOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI pull secret %s: %w", s.pullSecret, err)
+	}
+
+	if secret.Type != corev1.SecretTypeDockerConfigJson {
+		return nil, fmt.Errorf(
+			"OCI pull secret %s must be of type %q, got %q",
+			s.pullSecret, corev1.SecretTypeDockerConfigJson, secret.Type,
+		)
+	}
+
+	credentials, err := registry.CredentialsFromDockerConfigJSON(secret.Data[corev1.DockerConfigJsonKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials from OCI pull secret %s: %w", s.pullSecret, err)
+	}
+
+	return credentials, nil
 }
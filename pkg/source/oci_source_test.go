@@ -0,0 +1,196 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/internal/testregistry"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/registry"
+)
+
+func TestOCISource_VerifiesSignature(t *testing.T) {
+	reg := testregistry.New(t)
+	layer := reg.PushBlob(
+		t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, map[string][]byte{"ca.crt": []byte("a")}),
+	)
+	signed := reg.PushArtifact(t, "bundle", "signed", nil, layer)
+	reg.PushArtifact(t, "bundle", "unsigned", map[string]string{"unsigned": "true"}, layer)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.PushCosignSignature(t, "bundle", signed, key)
+
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	kc := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "keys"},
+		Data: map[string]string{
+			"cosign.pub": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		},
+	})
+
+	tests := []struct {
+		tag     string
+		wantErr bool
+	}{{
+		tag: "signed",
+	}, {
+		tag:     "unsigned",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.tag, func(t *testing.T) {
+			src, err := newOCISource(reg.Host + "/bundle:" + tt.tag + "?verifyKey=configmap/ns/keys/cosign.pub")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ociSrc := src.(*ociSource)
+			ociSrc.InjectKubernetesClient(kc)
+			ociSrc.InjectRegistryOptions(registry.ClientOptCAFile(reg.CAFile))
+
+			files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				if files != nil {
+					t.Errorf("expected no files but got %v", files)
+				}
+				return
+			}
+			if got := string(files["ca.crt"]); got != "a" {
+				t.Errorf("expected %q but got %q", "a", got)
+			}
+		})
+	}
+}
+
+func TestOCISource_ResolvesAndPinsDigests(t *testing.T) {
+	reg := testregistry.New(t)
+	layerA := reg.PushBlob(
+		t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, map[string][]byte{"ca.crt": []byte("a")}),
+	)
+	layerB := reg.PushBlob(
+		t, "bundle", ocispecv1.MediaTypeImageLayer, testregistry.Tarball(t, map[string][]byte{"ca.crt": []byte("b")}),
+	)
+	a := reg.PushArtifact(t, "bundle", "v1", nil, layerA)
+
+	newSource := func(t *testing.T, ref string) Source {
+		t.Helper()
+
+		src, err := newOCISource(reg.Host + "/bundle" + ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src.(*ociSource).InjectRegistryOptions(registry.ClientOptCAFile(reg.CAFile))
+		return src
+	}
+
+	getFiles := func(t *testing.T, src Source) (string, map[string]string) {
+		t.Helper()
+
+		ctx, recorder := metadata.WithRevisionRecorder(context.Background())
+		files, err := src.GetFiles(ctx, metadata.Metadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(files["ca.crt"]), recorder.Revisions()
+	}
+
+	t.Run("tag", func(t *testing.T) {
+		src := newSource(t, ":v1")
+		revisionKey := "oci::" + reg.Host + "/bundle:v1"
+
+		got, revisions := getFiles(t, src)
+		if got != "a" || revisions[revisionKey] != a.Digest.String() {
+			t.Fatalf("expected %q from %s but got %q from %v", "a", a.Digest, got, revisions)
+		}
+
+		b := reg.PushArtifact(t, "bundle", "v1", nil, layerB)
+		got, revisions = getFiles(t, src)
+		if got != "b" || revisions[revisionKey] != b.Digest.String() {
+			t.Fatalf("expected %q from %s but got %q from %v", "b", b.Digest, got, revisions)
+		}
+	})
+
+	t.Run("matching digest", func(t *testing.T) {
+		got, _ := getFiles(t, newSource(t, "@"+a.Digest.String()))
+		if got != "a" {
+			t.Errorf("expected %q but got %q", "a", got)
+		}
+	})
+
+	t.Run("mismatched digest", func(t *testing.T) {
+		// The v1 tag has been moved to b by the tag subtest.
+		src := newSource(t, ":v1@"+a.Digest.String())
+		if _, err := src.GetFiles(context.Background(), metadata.Metadata{}); err == nil {
+			t.Errorf("expected digest mismatch error")
+		}
+	})
+}
+
+func TestOCISource_RejectsOversizedBundles(t *testing.T) {
+	// The layer is a few kilobytes but decompresses to a file larger than the tarball limits.
+	var layerData bytes.Buffer
+	zw := gzip.NewWriter(&layerData)
+	bomb := testregistry.Tarball(t, map[string][]byte{"ca.crt": make([]byte, 2*defaultTarballLimits.maxFileSize)})
+	if _, err := zw.Write(bomb); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := testregistry.New(t)
+	layer := reg.PushBlob(t, "bundle", ocispecv1.MediaTypeImageLayerGzip, layerData.Bytes())
+	reg.PushArtifact(t, "bundle", "v1", nil, layer)
+
+	cacheDir := t.TempDir()
+	cache, err := registry.NewBundleCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := newOCISource(reg.Host + "/bundle:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(*ociSource).InjectRegistryOptions(registry.ClientOptCAFile(reg.CAFile), registry.ClientOptBundleCache(cache))
+
+	_, err = src.GetFiles(context.Background(), metadata.Metadata{})
+	var tarballErr *TarballError
+	if !errors.As(err, &tarballErr) || !errors.Is(err, ErrTarballLimitExceeded) {
+		t.Fatalf("expected a *TarballError for an exceeded limit but got %v", err)
+	}
+	bundles, err := os.ReadDir(filepath.Join(cacheDir, "bundles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundles) != 0 {
+		t.Errorf("expected no bundles to be cached but got %d", len(bundles))
+	}
+}
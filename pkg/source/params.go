@@ -0,0 +1,162 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// splitConfig splits a source config into the part before the first `?` and the query parameters following it, e.g.
+// `myregistry/cert-bundle:v1?pullSecret=ns/name`. Only the given parameter names are accepted.
+func splitConfig(cfg string, allowedParams ...string) (string, url.Values, error) {
+	base, rawQuery, found := strings.Cut(cfg, "?")
+	if !found {
+		return cfg, url.Values{}, nil
+	}
+
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid source parameters %q: %w", rawQuery, err)
+	}
+
+	for name := range params {
+		allowed := false
+		for _, a := range allowedParams {
+			if name == a {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", nil, fmt.Errorf("unsupported source parameter %q", name)
+		}
+	}
+
+	return base, params, nil
+}
+
+// parseNamespacedName parses a `<namespace>/<name>` reference to a Kubernetes object.
+func parseNamespacedName(s string) (types.NamespacedName, error) {
+	namespace, name, found := strings.Cut(s, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return types.NamespacedName{}, fmt.Errorf("invalid object reference %q, must be <namespace>/<name>", s)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// objectKeyRef references a single key of a Secret or ConfigMap.
+type objectKeyRef struct {
+	// Kind is either "secret" or "configmap".
+	Kind string
+	types.NamespacedName
+	Key string
+}
+
+func (r objectKeyRef) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", r.Kind, r.Namespace, r.Name, r.Key)
+}
+
+// parseObjectKeyRef parses a `<secret|configmap>/<namespace>/<name>/<key>` reference to a single key of a Secret or
+// ConfigMap.
+func parseObjectKeyRef(s string) (objectKeyRef, error) {
+	parts := strings.SplitN(s, "/", 4)
+	if len(parts) != 4 || parts[1] == "" || parts[2] == "" || parts[3] == "" ||
+		(parts[0] != "secret" && parts[0] != "configmap") {
+		return objectKeyRef{}, fmt.Errorf(
+			"invalid key reference %q, must be <secret|configmap>/<namespace>/<name>/<key>", s,
+		)
+	}
+	return objectKeyRef{
+		Kind:           parts[0],
+		NamespacedName: types.NamespacedName{Namespace: parts[1], Name: parts[2]},
+		Key:            parts[3],
+	}, nil
+}
+
+// readObjectKey reads the value of the referenced key from the API server.
+func readObjectKey(ctx context.Context, kc kubernetes.Interface, ref objectKeyRef) ([]byte, error) {
+	switch ref.Kind {
+	case "secret":
+		secret, err := kc.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, v1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", ref.NamespacedName, err)
+		}
+		if v, ok := secret.Data[ref.Key]; ok {
+			return v, nil
+		}
+	case "configmap":
+		cm, err := kc.CoreV1().ConfigMaps(ref.Namespace).Get(ctx, ref.Name, v1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configmap %s: %w", ref.NamespacedName, err)
+		}
+		if v, ok := cm.Data[ref.Key]; ok {
+			return []byte(v), nil
+		}
+		if v, ok := cm.BinaryData[ref.Key]; ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("key %q not found in %s %s", ref.Key, ref.Kind, ref.NamespacedName)
+}
+
+// itemParam selects a key of a ConfigMap or Secret, optionally mapped to a path, e.g. `item=ca.crt:certs/corp.pem`.
+const itemParam = "item"
+
+// keyToPath maps a key of a ConfigMap or Secret to the path of the file it is written to, like the items of projected
+// volumes.
+type keyToPath struct {
+	Key  string
+	Path string
+}
+
+// parseItems parses `<key>[:<path>]` items. The path defaults to the key and must be a relative path that does not
+// leave the volume; subdirectories are created as required.
+func parseItems(items []string) ([]keyToPath, error) {
+	var (
+		parsed []keyToPath
+		paths  = map[string]bool{}
+	)
+	for _, item := range items {
+		key, p, found := strings.Cut(item, ":")
+		if !found {
+			p = key
+		}
+		cleaned := path.Clean(p)
+		if key == "" || p == "" || path.IsAbs(p) || cleaned != p || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return nil, fmt.Errorf("invalid item %q, must be <key>[:<relative path>]", item)
+		}
+		if paths[p] {
+			return nil, fmt.Errorf("invalid item %q, more than one key is mapped to %q", item, p)
+		}
+		paths[p] = true
+		parsed = append(parsed, keyToPath{Key: key, Path: p})
+	}
+	return parsed, nil
+}
+
+// selectItems returns the files for the selected keys of data, keyed by their mapped path. All keys are returned if no
+// items are selected. An error is returned if a selected key does not exist.
+func selectItems(data map[string][]byte, items []keyToPath, object string) (map[string][]byte, error) {
+	if len(items) == 0 {
+		return data, nil
+	}
+
+	files := make(map[string][]byte, len(items))
+	for _, item := range items {
+		v, ok := data[item.Key]
+		if !ok {
+			return nil, fmt.Errorf("key %q not found in %s", item.Key, object)
+		}
+		files[item.Path] = v
+	}
+	return files, nil
+}
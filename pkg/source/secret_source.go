@@ -8,43 +8,91 @@ import (
 	"fmt"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
 )
 
-var _ WithKubernetesClient = &secretSource{}
+var (
+	_ WithKubernetesClient = &secretSource{}
+	_ Runnable             = &secretSource{}
+	_ Notifier             = &secretSource{}
+)
 
 func newSecretSource(cfg string) (Source, error) {
-	cm := strings.Split(cfg, "/")
+	ref, params, err := splitConfig(cfg, selectorParam, itemParam)
+	if err != nil {
+		return nil, err
+	}
+	namespace, name, found := strings.Cut(ref, "/")
+	if !found || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid secret source config: %s", cfg)
+	}
 
-	if len(cm) != 2 {
-		return nil, fmt.Errorf("invalid configmap source config: %s", cfg)
+	items, err := parseItems(params[itemParam])
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret source config: %w", err)
+	}
+
+	if selector := params.Get(selectorParam); selector != "" {
+		return newSelectorSource(kindSecret, namespace, name, selector, items)
+	}
+	if namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid secret source config: %s", cfg)
 	}
 
 	return &secretSource{
-		namespace: cm[0],
-		name:      cm[1],
+		namespace: namespace,
+		name:      name,
+		items:     items,
 	}, nil
 }
 
 type secretSource struct {
+	changeNotifier
+
 	namespace string
 	name      string
+	// items are the selected keys and the paths they are written to. All keys are written if empty.
+	items []keyToPath
 
 	kc kubernetes.Interface
+
+	informerFactory informers.SharedInformerFactory
+	lister          corev1listers.SecretNamespaceLister
+	synced          cache.InformerSynced
 }
 
 func (s *secretSource) InjectKubernetesClient(kc kubernetes.Interface) {
 	s.kc = kc
+
+	s.informerFactory = newSingleObjectInformerFactory(kc, s.namespace, s.name)
+	informer := s.informerFactory.Core().V1().Secrets()
+	// AddEventHandler only errors if the informer has already been stopped, which cannot be the case here.
+	_, _ = informer.Informer().AddEventHandler(changeHandler(s.notify))
+	s.lister = informer.Lister().Secrets(s.namespace)
+	s.synced = informer.Informer().HasSynced
+}
+
+// Start starts watching the secret and waits until it has been cached. Until Start has returned, GetFiles reads the
+// secret directly from the API server.
+func (s *secretSource) Start(ctx context.Context) error {
+	if err := startInformers(ctx, s.informerFactory); err != nil {
+		return fmt.Errorf("failed to watch secret %s/%s: %w", s.namespace, s.name, err)
+	}
+	return nil
 }
 
 func (s *secretSource) GetFiles(
 	ctx context.Context,
 	meta metadata.Metadata,
 ) (map[string][]byte, error) {
-	secret, err := s.kc.CoreV1().Secrets(s.namespace).Get(ctx, s.name, v1.GetOptions{})
+	secret, err := s.getSecret(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read files from secret source: %w", err)
 	}
@@ -54,5 +102,12 @@ func (s *secretSource) GetFiles(
 		files[k] = v
 	}
 
-	return files, nil
+	return selectItems(files, s.items, fmt.Sprintf("secret %s/%s", s.namespace, s.name))
+}
+
+func (s *secretSource) getSecret(ctx context.Context) (*corev1.Secret, error) {
+	if s.synced != nil && s.synced() {
+		return s.lister.Get(s.name)
+	}
+	return s.kc.CoreV1().Secrets(s.namespace).Get(ctx, s.name, v1.GetOptions{})
 }
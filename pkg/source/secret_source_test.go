@@ -0,0 +1,42 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestSecretSource_ItemsExcludeOtherKeys(t *testing.T) {
+	kc := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "issuer-ca"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":                []byte("ca"),
+			corev1.TLSCertKey:       []byte("cert"),
+			corev1.TLSPrivateKeyKey: []byte("key"),
+		},
+	})
+
+	src, err := newSecretSource("ns/issuer-ca?item=ca.crt:issuer/ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(WithKubernetesClient).InjectKubernetesClient(kc)
+
+	files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"issuer/ca.pem": []byte("ca")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q but got %q", want, files)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+const (
+	selectorParam = "selector"
+
+	// allNamespaces selects objects in all namespaces in place of a namespace.
+	allNamespaces = "*"
+
+	kindConfigMap = "configmap"
+	kindSecret    = "secret"
+)
+
+var (
+	_ WithKubernetesClient = &selectorSource{}
+	_ Runnable             = &selectorSource{}
+	_ Notifier             = &selectorSource{}
+)
+
+// newSelectorSource returns a source for the configmaps or secrets matching the label selector in the namespace, which
+// is `*` for all namespaces. The config is of the form `<namespace>/?selector=<label selector>`.
+func newSelectorSource(kind, namespace, name, selector string, items []keyToPath) (Source, error) {
+	if name != "" || namespace == "" {
+		return nil, fmt.Errorf(
+			"invalid %s source config, must be <namespace|*>/?%s=<label selector> when selecting by label",
+			kind, selectorParam,
+		)
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s source label selector %q: %w", kind, selector, err)
+	}
+
+	if namespace == allNamespaces {
+		namespace = v1.NamespaceAll
+	}
+
+	return &selectorSource{
+		kind:      kind,
+		namespace: namespace,
+		selector:  sel,
+		items:     items,
+	}, nil
+}
+
+// selectorSource returns the keys of all configmaps or secrets matching a label selector. Each key is returned as a
+// file named `<namespace>/<name>/<key>`, so that keys with the same name in different objects do not collide.
+type selectorSource struct {
+	changeNotifier
+
+	kind      string
+	namespace string
+	selector  labels.Selector
+	// items are the selected keys of each object and the paths they are written to, below `<namespace>/<name>`. All
+	// keys are written if empty.
+	items []keyToPath
+
+	kc kubernetes.Interface
+
+	informerFactory informers.SharedInformerFactory
+	configMapLister corev1listers.ConfigMapLister
+	secretLister    corev1listers.SecretLister
+	synced          cache.InformerSynced
+}
+
+func (s *selectorSource) InjectKubernetesClient(kc kubernetes.Interface) {
+	s.kc = kc
+
+	s.informerFactory = informers.NewSharedInformerFactoryWithOptions(
+		kc,
+		0,
+		informers.WithNamespace(s.namespace),
+		informers.WithTweakListOptions(func(opts *v1.ListOptions) {
+			opts.LabelSelector = s.selector.String()
+		}),
+	)
+
+	var informer cache.SharedIndexInformer
+	switch s.kind {
+	case kindConfigMap:
+		configMaps := s.informerFactory.Core().V1().ConfigMaps()
+		s.configMapLister = configMaps.Lister()
+		informer = configMaps.Informer()
+	case kindSecret:
+		secrets := s.informerFactory.Core().V1().Secrets()
+		s.secretLister = secrets.Lister()
+		informer = secrets.Informer()
+	}
+	// AddEventHandler only errors if the informer has already been stopped, which cannot be the case here.
+	_, _ = informer.AddEventHandler(changeHandler(s.notify))
+	s.synced = informer.HasSynced
+}
+
+// Start starts watching the matching objects and waits until they have been cached. Until Start has returned,
+// GetFiles lists the objects directly from the API server.
+func (s *selectorSource) Start(ctx context.Context) error {
+	if err := startInformers(ctx, s.informerFactory); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", s, err)
+	}
+	return nil
+}
+
+func (s *selectorSource) GetFiles(
+	ctx context.Context,
+	meta metadata.Metadata,
+) (map[string][]byte, error) {
+	var (
+		files map[string][]byte
+		err   error
+	)
+	switch s.kind {
+	case kindConfigMap:
+		files, err = s.configMapFiles(ctx)
+	case kindSecret:
+		files, err = s.secretFiles(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files from %s: %w", s, err)
+	}
+	return files, nil
+}
+
+func (s *selectorSource) configMapFiles(ctx context.Context) (map[string][]byte, error) {
+	var (
+		configMaps []*corev1.ConfigMap
+		err        error
+	)
+	if s.synced != nil && s.synced() {
+		configMaps, err = s.configMapLister.List(s.selector)
+	} else {
+		var list *corev1.ConfigMapList
+		list, err = s.kc.CoreV1().ConfigMaps(s.namespace).List(ctx, v1.ListOptions{LabelSelector: s.selector.String()})
+		if list != nil {
+			for i := range list.Items {
+				configMaps = append(configMaps, &list.Items[i])
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := newObjectMerger(s)
+	for _, cm := range configMaps {
+		data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+		for k, v := range cm.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range cm.BinaryData {
+			data[k] = v
+		}
+		m.add(cm.Namespace, cm.Name, data)
+	}
+	return m.files()
+}
+
+func (s *selectorSource) secretFiles(ctx context.Context) (map[string][]byte, error) {
+	var (
+		secrets []*corev1.Secret
+		err     error
+	)
+	if s.synced != nil && s.synced() {
+		secrets, err = s.secretLister.List(s.selector)
+	} else {
+		var list *corev1.SecretList
+		list, err = s.kc.CoreV1().Secrets(s.namespace).List(ctx, v1.ListOptions{LabelSelector: s.selector.String()})
+		if list != nil {
+			for i := range list.Items {
+				secrets = append(secrets, &list.Items[i])
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := newObjectMerger(s)
+	for _, secret := range secrets {
+		m.add(secret.Namespace, secret.Name, secret.Data)
+	}
+	return m.files()
+}
+
+// objectMerger merges the selected keys of the matching objects, each below `<namespace>/<name>`. Objects lacking a
+// selected key are skipped, so that a single object in any namespace cannot break the source for every volume.
+type objectMerger struct {
+	source *selectorSource
+
+	merged  map[string][]byte
+	added   int
+	skipErr error
+}
+
+func newObjectMerger(s *selectorSource) *objectMerger {
+	return &objectMerger{source: s, merged: map[string][]byte{}}
+}
+
+// add adds the selected keys of the object's data, or skips the object if it lacks any of them.
+func (m *objectMerger) add(namespace, name string, data map[string][]byte) {
+	selected, err := selectItems(data, m.source.items, fmt.Sprintf("%s %s/%s", m.source.kind, namespace, name))
+	if err != nil {
+		klog.Warningf("Skipping object matching %s: %v", m.source, err)
+		if m.skipErr == nil {
+			m.skipErr = err
+		}
+		return
+	}
+	for p, v := range selected {
+		m.merged[path.Join(namespace, name, p)] = v
+	}
+	m.added++
+}
+
+// files returns the merged files. It fails if objects match but all of them have been skipped.
+func (m *objectMerger) files() (map[string][]byte, error) {
+	if m.added == 0 && m.skipErr != nil {
+		return nil, fmt.Errorf("no matching object contains the selected keys: %w", m.skipErr)
+	}
+	return m.merged, nil
+}
+
+func (s *selectorSource) String() string {
+	if s.namespace == v1.NamespaceAll {
+		return fmt.Sprintf("%ss matching %q in all namespaces", s.kind, s.selector)
+	}
+	return fmt.Sprintf("%ss matching %q in namespace %s", s.kind, s.selector, s.namespace)
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestSelectorSource_MergesMatchingObjectsAndNotifiesChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	include := map[string]string{"trusted-ca.d2iq.com/include": "true"}
+	kc := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Namespace: "team-a", Name: "ca", Labels: include},
+			Data:       map[string]string{"ca.crt": "a"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Namespace: "team-b", Name: "ca", Labels: include},
+			Data:       map[string]string{"ca.crt": "b"},
+			BinaryData: map[string][]byte{"ca.der": []byte("b")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Namespace: "team-c", Name: "ca"},
+			Data:       map[string]string{"ca.crt": "c"},
+		},
+	)
+
+	src, err := newConfigmapSource("*/?selector=trusted-ca.d2iq.com/include%3Dtrue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	selSrc := src.(*selectorSource)
+	selSrc.InjectKubernetesClient(kc)
+
+	want := map[string][]byte{
+		"team-a/ca/ca.crt": []byte("a"),
+		"team-b/ca/ca.crt": []byte("b"),
+		"team-b/ca/ca.der": []byte("b"),
+	}
+
+	// Objects are listed from the API server before the source has been started.
+	files, err := src.GetFiles(ctx, metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q before start but got %q", want, files)
+	}
+
+	if err := selSrc.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	files, err = src.GetFiles(ctx, metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q after start but got %q", want, files)
+	}
+
+	if err := kc.CoreV1().ConfigMaps("team-a").Delete(ctx, "ca", v1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notifications for the initial adds may still be pending, so wait until a notification reflects the delete.
+	want = map[string][]byte{"team-b/ca/ca.crt": []byte("b"), "team-b/ca/ca.der": []byte("b")}
+	for !reflect.DeepEqual(files, want) {
+		select {
+		case <-selSrc.Changes():
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for change notification, expected %q but got %q", want, files)
+		}
+		files, err = src.GetFiles(ctx, metadata.Metadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSelectorSource_Secrets(t *testing.T) {
+	kc := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "a", Labels: map[string]string{"ca": "true"}},
+			Data:       map[string][]byte{"ca.crt": []byte("a"), "tls.key": []byte("key")},
+		},
+		&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Namespace: "other", Name: "b", Labels: map[string]string{"ca": "true"}},
+			Data:       map[string][]byte{"ca.crt": []byte("b")},
+		},
+	)
+
+	src, err := newSecretSource("ns/?selector=ca&item=ca.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(WithKubernetesClient).InjectKubernetesClient(kc)
+
+	files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]byte{"ns/a/ca.crt": []byte("a")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("expected %q but got %q", want, files)
+	}
+}
+
+func TestSelectorSource_SkipsObjectsWithoutSelectedKeys(t *testing.T) {
+	labels := map[string]string{"ca": "true"}
+	withKey := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "a", Labels: labels},
+		Data:       map[string][]byte{"ca.crt": []byte("a")},
+	}
+	withoutKey := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Namespace: "other", Name: "b", Labels: labels},
+		Data:       map[string][]byte{"tls.crt": []byte("b")},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    map[string][]byte
+		wantErr bool
+	}{{
+		name:    "object without key",
+		objects: []runtime.Object{withKey, withoutKey},
+		want:    map[string][]byte{"ns/a/ca.crt": []byte("a")},
+	}, {
+		name:    "no object with key",
+		objects: []runtime.Object{withoutKey},
+		wantErr: true,
+	}, {
+		name:    "no matching objects",
+		objects: nil,
+		want:    map[string][]byte{},
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := newSecretSource("*/?selector=ca&item=ca.crt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			src.(WithKubernetesClient).InjectKubernetesClient(fake.NewSimpleClientset(tt.objects...))
+
+			files, err := src.GetFiles(context.Background(), metadata.Metadata{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t but got: %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, files)
+			}
+		})
+	}
+}
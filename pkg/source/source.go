@@ -7,21 +7,29 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sync"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/registry"
 )
 
 var (
-	sourceRegexp = regexp.MustCompile(`^([A-Za-z0-9]+)::(.+)$`)
+	sourceRegexp = regexp.MustCompile(`^([A-Za-z0-9]+)::(.*)$`)
 
 	getters = map[string]func(string) (Source, error){
-		"test":      newTestSource,
-		"configmap": newConfigmapSource,
-		"secret":    newSecretSource,
-		"oci":       newOCISource,
+		"test":               newTestSource,
+		"configmap":          newConfigmapSource,
+		"secret":             newSecretSource,
+		"oci":                newOCISource,
+		"https":              newHTTPSSource,
+		"hostpath":           newHostPathSource,
+		"mozilla":            newMozillaSource,
+		"clustertrustbundle": newClusterTrustBundleSource,
+		"certmanager":        newCertManagerSource,
 	}
 )
 
@@ -29,6 +37,31 @@ type Source interface {
 	GetFiles(context.Context, metadata.Metadata) (map[string][]byte, error)
 }
 
+// Runnable is implemented by sources that run background processes, e.g. informers, to serve files. Start blocks until
+// the source is ready and the background processes run until the context is done.
+type Runnable interface {
+	Start(context.Context) error
+}
+
+// Notifier is implemented by sources that can notify when their files have changed, allowing volumes to be refreshed
+// without waiting for the next refresh interval.
+type Notifier interface {
+	Changes() <-chan struct{}
+}
+
+// VolumeNotifier is implemented by notifiers that know which volumes use the files that have changed. ChangedVolumes
+// returns the IDs of the volumes whose files have changed since it was last called.
+type VolumeNotifier interface {
+	Notifier
+	ChangedVolumes() []string
+}
+
+// VolumeReleaser is implemented by sources that hold resources for the volumes they serve files to. ReleaseVolume is
+// called once a volume is no longer published.
+type VolumeReleaser interface {
+	ReleaseVolume(volumeID string)
+}
+
 type WithRESTConfig interface {
 	InjectRESTConfig(*rest.Config)
 }
@@ -37,7 +70,66 @@ type WithKubernetesClient interface {
 	InjectKubernetesClient(kubernetes.Interface)
 }
 
+// WithDynamicClient is implemented by sources that read Kubernetes APIs not covered by the typed client.
+type WithDynamicClient interface {
+	InjectDynamicClient(dynamic.Interface)
+}
+
+// WithRegistryOptions is implemented by sources that pull from OCI registries.
+type WithRegistryOptions interface {
+	InjectRegistryOptions(...registry.ClientOption)
+}
+
+// Factory creates sources, sharing clients between all the sources it creates.
+type Factory struct {
+	restCfg *rest.Config
+
+	registryOpts []registry.ClientOption
+
+	hostPathOpts HostPathOptions
+
+	kcOnce sync.Once
+	kc     kubernetes.Interface
+	kcErr  error
+
+	dcOnce sync.Once
+	dc     dynamic.Interface
+	dcErr  error
+}
+
+// FactoryOption configures a Factory.
+type FactoryOption func(*Factory)
+
+// FactoryOptRegistryOptions returns a function that sets the options used to create registry clients for sources
+// pulling from OCI registries.
+func FactoryOptRegistryOptions(opts ...registry.ClientOption) FactoryOption {
+	return func(f *Factory) {
+		f.registryOpts = opts
+	}
+}
+
+// FactoryOptHostPathOptions returns a function that sets the options of sources reading from the node filesystem.
+func FactoryOptHostPathOptions(opts HostPathOptions) FactoryOption {
+	return func(f *Factory) {
+		f.hostPathOpts = opts
+	}
+}
+
+// NewFactory returns a factory that creates sources using the given rest config to connect to the Kubernetes API.
+func NewFactory(restCfg *rest.Config, opts ...FactoryOption) *Factory {
+	f := &Factory{restCfg: restCfg}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
 func New(src string, restCfg *rest.Config) (Source, error) {
+	return NewFactory(restCfg).New(src)
+}
+
+// New creates the source described by src, e.g. `configmap::kube-system/ca-certs`.
+func (f *Factory) New(src string) (Source, error) {
 	getterName, getterConfig := getSource(src)
 	getterFunc, ok := getters[getterName]
 	if ok {
@@ -47,15 +139,29 @@ func New(src string, restCfg *rest.Config) (Source, error) {
 		}
 
 		if gc, ok := getter.(WithKubernetesClient); ok {
-			kc, err := kubernetes.NewForConfig(restCfg)
+			kc, err := f.kubernetesClient()
 			if err != nil {
 				return nil, err
 			}
 
 			gc.InjectKubernetesClient(kc)
 		}
+		if gc, ok := getter.(WithDynamicClient); ok {
+			dc, err := f.dynamicClient()
+			if err != nil {
+				return nil, err
+			}
+
+			gc.InjectDynamicClient(dc)
+		}
 		if rc, ok := getter.(WithRESTConfig); ok {
-			rc.InjectRESTConfig(restCfg)
+			rc.InjectRESTConfig(f.restCfg)
+		}
+		if ro, ok := getter.(WithRegistryOptions); ok {
+			ro.InjectRegistryOptions(f.registryOpts...)
+		}
+		if ho, ok := getter.(WithHostPathOptions); ok {
+			ho.InjectHostPathOptions(f.hostPathOpts)
 		}
 
 		return getter, nil
@@ -64,6 +170,20 @@ func New(src string, restCfg *rest.Config) (Source, error) {
 	return nil, fmt.Errorf("unsupported source: %s", src)
 }
 
+func (f *Factory) kubernetesClient() (kubernetes.Interface, error) {
+	f.kcOnce.Do(func() {
+		f.kc, f.kcErr = kubernetes.NewForConfig(f.restCfg)
+	})
+	return f.kc, f.kcErr
+}
+
+func (f *Factory) dynamicClient() (dynamic.Interface, error) {
+	f.dcOnce.Do(func() {
+		f.dc, f.dcErr = dynamic.NewForConfig(f.restCfg)
+	})
+	return f.dc, f.dcErr
+}
+
 func getSource(src string) (getterName, getterConfig string) {
 	if ms := sourceRegexp.FindStringSubmatch(src); ms != nil {
 		return ms[1], ms[2]
@@ -0,0 +1,186 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/registry"
+)
+
+var (
+	// ErrUnsafeTarballEntry is returned, wrapped in a TarballError, if a tarball contains an entry that is not a
+	// regular file or directory, or whose name is not a relative path within the tarball.
+	ErrUnsafeTarballEntry = errors.New("unsafe tarball entry")
+	// ErrTarballLimitExceeded is returned, wrapped in a TarballError, if a tarball exceeds the size or entry limits.
+	ErrTarballLimitExceeded = errors.New("tarball limit exceeded")
+)
+
+// TarballError is returned by sources reading tarballs if the tarball contains an unsafe entry or exceeds a limit. It
+// wraps either ErrUnsafeTarballEntry or ErrTarballLimitExceeded.
+type TarballError struct {
+	// Entry is the name of the offending entry as stored in the tarball.
+	Entry string
+	// Reason describes why the entry was rejected.
+	Reason string
+
+	err error
+}
+
+func (e *TarballError) Error() string {
+	return fmt.Sprintf("%v: entry %q: %s", e.err, e.Entry, e.Reason)
+}
+
+func (e *TarballError) Unwrap() error {
+	return e.err
+}
+
+// tarballLimits limit the resources used to read a tarball.
+type tarballLimits struct {
+	// maxFileSize is the maximum size in bytes of a single file.
+	maxFileSize int64
+	// maxTotalSize is the maximum size in bytes of all files.
+	maxTotalSize int64
+	// maxEntries is the maximum number of entries, including directories.
+	maxEntries int
+}
+
+// defaultTarballLimits are generous for bundles of CA certificates, which are a few hundred kilobytes even for complete
+// public root stores, while bounding the memory used for malicious or accidentally huge tarballs.
+var defaultTarballLimits = tarballLimits{
+	maxFileSize:  4 << 20,
+	maxTotalSize: 16 << 20,
+	maxEntries:   4096,
+}
+
+// bundleLimits returns the limits as limits of bundles pulled from OCI registries.
+func (l tarballLimits) bundleLimits() registry.BundleLimits {
+	return registry.BundleLimits{
+		MaxFileSize:  l.maxFileSize,
+		MaxTotalSize: l.maxTotalSize,
+		MaxEntries:   l.maxEntries,
+	}
+}
+
+// bundleLimitError returns a *TarballError if the error is caused by a bundle exceeding the limits while being pulled,
+// and the error unchanged otherwise.
+func bundleLimitError(err error) error {
+	var limitErr *registry.BundleLimitError
+	if errors.As(err, &limitErr) {
+		return &TarballError{Entry: limitErr.Entry, Reason: limitErr.Reason, err: ErrTarballLimitExceeded}
+	}
+	return err
+}
+
+// readTarball returns the files in the tarball, keyed by their cleaned name in the tarball, within the default limits.
+// Directories are skipped. See tarballLimits.read.
+func readTarball(r *tar.Reader) (map[string][]byte, error) {
+	return defaultTarballLimits.read(r)
+}
+
+// read returns the files in the tarball, keyed by their cleaned name in the tarball. Directories are skipped. Files
+// appearing more than once are replaced by their last entry.
+//
+// Only regular files and directories with relative names within the tarball are accepted, so that tarballs cannot
+// write outside of the volume or create links or devices. Unsafe entries and tarballs exceeding the limits are
+// rejected with a *TarballError.
+func (l tarballLimits) read(r *tar.Reader) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	var (
+		entries   int
+		totalSize int64
+	)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tarball: %w", err)
+		}
+
+		entries++
+		if entries > l.maxEntries {
+			return nil, &TarballError{
+				Entry:  header.Name,
+				Reason: fmt.Sprintf("tarball has more than %d entries", l.maxEntries),
+				err:    ErrTarballLimitExceeded,
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg, tar.TypeRegA: //nolint:staticcheck // TypeRegA is still written by some tar implementations.
+		default:
+			return nil, &TarballError{
+				Entry:  header.Name,
+				Reason: fmt.Sprintf("unsupported entry type %q, only regular files and directories are allowed", header.Typeflag),
+				err:    ErrUnsafeTarballEntry,
+			}
+		}
+
+		name, err := cleanTarballName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Size > l.maxFileSize {
+			return nil, &TarballError{
+				Entry:  header.Name,
+				Reason: fmt.Sprintf("file size %d exceeds the limit of %d bytes", header.Size, l.maxFileSize),
+				err:    ErrTarballLimitExceeded,
+			}
+		}
+		totalSize += header.Size
+		if totalSize > l.maxTotalSize {
+			return nil, &TarballError{
+				Entry:  header.Name,
+				Reason: fmt.Sprintf("total size of files exceeds the limit of %d bytes", l.maxTotalSize),
+				err:    ErrTarballLimitExceeded,
+			}
+		}
+
+		var buf bytes.Buffer
+		// The tar reader never reads more than the size in the header, the limit is only a safeguard.
+		_, err = io.Copy(&buf, io.LimitReader(r, l.maxFileSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tarball: %w", err)
+		}
+
+		files[name] = buf.Bytes()
+	}
+
+	return files, nil
+}
+
+// cleanTarballName returns the cleaned name of a tarball entry, without a leading `./`. Absolute names, names
+// escaping the tarball, and names with elements starting with `..`, which are reserved by the atomic writer, are
+// rejected.
+func cleanTarballName(name string) (string, error) {
+	unsafe := func(reason string) error {
+		return &TarballError{Entry: name, Reason: reason, err: ErrUnsafeTarballEntry}
+	}
+
+	if path.IsAbs(name) {
+		return "", unsafe("absolute paths are not allowed")
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", unsafe("empty paths are not allowed")
+	}
+	for _, elem := range strings.Split(cleaned, "/") {
+		if strings.HasPrefix(elem, "..") {
+			return "", unsafe("path elements must not start with '..'")
+		}
+	}
+	return cleaned, nil
+}
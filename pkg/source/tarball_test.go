@@ -0,0 +1,128 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTarball(t *testing.T) {
+	limits := tarballLimits{maxFileSize: 8, maxTotalSize: 16, maxEntries: 4}
+
+	tests := []struct {
+		name    string
+		entries []*tar.Header
+		want    map[string][]byte
+		wantErr error
+	}{{
+		name: "regular files and directories",
+		entries: []*tar.Header{
+			{Name: "./certs/", Typeflag: tar.TypeDir},
+			{Name: "./certs/a.pem", Typeflag: tar.TypeReg, Size: 1},
+			{Name: "b.pem", Typeflag: tar.TypeReg, Size: 1},
+		},
+		want: map[string][]byte{"certs/a.pem": []byte("x"), "b.pem": []byte("x")},
+	}, {
+		name:    "parent traversal",
+		entries: []*tar.Header{{Name: "../../etc/passwd", Typeflag: tar.TypeReg, Size: 1}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "nested parent traversal",
+		entries: []*tar.Header{{Name: "certs/../../a.pem", Typeflag: tar.TypeReg, Size: 1}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "absolute path",
+		entries: []*tar.Header{{Name: "/etc/passwd", Typeflag: tar.TypeReg, Size: 1}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "atomic writer reserved name",
+		entries: []*tar.Header{{Name: "..data/a.pem", Typeflag: tar.TypeReg, Size: 1}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "symlink",
+		entries: []*tar.Header{{Name: "a.pem", Typeflag: tar.TypeSymlink, Linkname: "/etc/shadow"}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "hard link",
+		entries: []*tar.Header{{Name: "a.pem", Typeflag: tar.TypeLink, Linkname: "/etc/shadow"}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "character device",
+		entries: []*tar.Header{{Name: "null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "fifo",
+		entries: []*tar.Header{{Name: "fifo", Typeflag: tar.TypeFifo}},
+		wantErr: ErrUnsafeTarballEntry,
+	}, {
+		name:    "file too large",
+		entries: []*tar.Header{{Name: "a.pem", Typeflag: tar.TypeReg, Size: 9}},
+		wantErr: ErrTarballLimitExceeded,
+	}, {
+		name: "total size too large",
+		entries: []*tar.Header{
+			{Name: "a.pem", Typeflag: tar.TypeReg, Size: 8},
+			{Name: "b.pem", Typeflag: tar.TypeReg, Size: 8},
+			{Name: "c.pem", Typeflag: tar.TypeReg, Size: 1},
+		},
+		wantErr: ErrTarballLimitExceeded,
+	}, {
+		name: "too many entries",
+		entries: []*tar.Header{
+			{Name: "a/", Typeflag: tar.TypeDir},
+			{Name: "b/", Typeflag: tar.TypeDir},
+			{Name: "c/", Typeflag: tar.TypeDir},
+			{Name: "d/", Typeflag: tar.TypeDir},
+			{Name: "e/", Typeflag: tar.TypeDir},
+		},
+		wantErr: ErrTarballLimitExceeded,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := limits.read(tar.NewReader(bytes.NewReader(testTarball(t, tt.entries))))
+			if tt.wantErr != nil {
+				var tarballErr *TarballError
+				if !errors.As(err, &tarballErr) || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected a TarballError wrapping %q but got error %v and files %q", tt.wantErr, err, files)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("expected %q but got %q", tt.want, files)
+			}
+		})
+	}
+}
+
+// testTarball returns a tarball of the entries. Regular files are filled with `x` up to their size.
+func testTarball(t *testing.T, entries []*tar.Header) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range entries {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(strings.Repeat("x", int(header.Size)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -5,16 +5,63 @@ package source
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
 
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
 )
 
+var (
+	testCAOnce sync.Once
+	testCAPEM  []byte
+	testCAErr  error
+)
+
 func newTestSource(_ string) (Source, error) {
 	return testSource{}, nil
 }
 
+// testSource returns a self-signed CA certificate generated once per process.
 type testSource struct{}
 
 func (testSource) GetFiles(_ context.Context, _ metadata.Metadata) (map[string][]byte, error) {
-	return map[string][]byte{"a": []byte("b")}, nil
+	testCAOnce.Do(func() {
+		testCAPEM, testCAErr = generateTestCA()
+	})
+	if testCAErr != nil {
+		return nil, testCAErr
+	}
+	return map[string][]byte{"ca.crt": testCAPEM}, nil
+}
+
+func generateTestCA() ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate test CA key: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "csi-driver-trusted-ca test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate test CA certificate: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
 }
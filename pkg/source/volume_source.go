@@ -0,0 +1,209 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	csiapi "github.com/d2iq-labs/csi-driver-trusted-ca/pkg/apis/v1alpha1"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+var (
+	_ Runnable       = &volumeSource{}
+	_ VolumeNotifier = &volumeSource{}
+	_ VolumeReleaser = &volumeSource{}
+)
+
+// NewVolumeSource returns a source that retrieves files from the source named in the volume's
+// `trusted-ca.csi.labs.d2iq.com/source` attribute, falling back to the default source if the attribute is not set.
+// Sources named in volume attributes are created on first use via the factory and shared between all volumes
+// referencing them, and stopped once no volume references them anymore. Volumes may only reference sources permitted
+// for the pod's namespace by the allowlist.
+func NewVolumeSource(factory *Factory, defaultSource Source, allowlist Allowlist) Source {
+	return &volumeSource{
+		factory:        factory,
+		defaultSource:  defaultSource,
+		allowlist:      allowlist,
+		sources:        map[string]*cachedSource{},
+		volumeSources:  map[string]string{},
+		defaultVolumes: map[string]struct{}{},
+		changedVolumes: map[string]struct{}{},
+	}
+}
+
+type volumeSource struct {
+	changeNotifier
+
+	factory       *Factory
+	defaultSource Source
+	allowlist     Allowlist
+
+	lock sync.Mutex
+	// sources caches the sources referenced by volumes, keyed by source string.
+	sources map[string]*cachedSource
+	// volumeSources are the source strings referenced by volumes, keyed by volume ID.
+	volumeSources map[string]string
+	// defaultVolumes are the IDs of the volumes using the default source.
+	defaultVolumes map[string]struct{}
+	// changedVolumes are the IDs of the volumes whose source has notified changes since ChangedVolumes was last called.
+	changedVolumes map[string]struct{}
+	// stopCh is set once the volume source has been started. Sources created after this are started immediately and
+	// stopped when the channel is closed.
+	stopCh <-chan struct{}
+}
+
+// cachedSource is a source referenced by volumes.
+type cachedSource struct {
+	Source
+
+	// volumes are the IDs of the volumes referencing the source.
+	volumes map[string]struct{}
+	// cancel stops the source. It is nil if the source has not been started.
+	cancel context.CancelFunc
+}
+
+// Start starts the default source if required. Sources referenced by volumes are started as they are created.
+func (s *volumeSource) Start(ctx context.Context) error {
+	if err := s.startSource(ctx, s.defaultSource, s.defaultVolumes); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopCh = ctx.Done()
+
+	return nil
+}
+
+func (s *volumeSource) GetFiles(
+	ctx context.Context,
+	meta metadata.Metadata,
+) (map[string][]byte, error) {
+	src, ok := meta.VolumeContext[csiapi.SourceKey]
+	if !ok {
+		s.lock.Lock()
+		s.defaultVolumes[meta.VolumeID] = struct{}{}
+		s.lock.Unlock()
+		return s.defaultSource.GetFiles(ctx, meta)
+	}
+
+	namespace := meta.VolumeContext[csiapi.K8sVolumeContextKeyPodNamespace]
+	if !s.allowlist.Allows(namespace, src) {
+		return nil, fmt.Errorf("source %q is not allowed for pods in namespace %q", src, namespace)
+	}
+
+	volSource, err := s.sourceFor(src, meta.VolumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return volSource.GetFiles(ctx, meta)
+}
+
+// sourceFor returns the cached source for the source string, creating it if it does not exist yet, and records that
+// the volume references it.
+func (s *volumeSource) sourceFor(src, volumeID string) (Source, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	cached, ok := s.sources[src]
+	if !ok {
+		created, err := s.factory.New(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create source %q: %w", src, err)
+		}
+		cached = &cachedSource{Source: created, volumes: map[string]struct{}{}}
+
+		if s.stopCh != nil {
+			// The context is cancelled when the source is evicted or the volume source is stopped.
+			ctx, cancel := wait.ContextForChannel(s.stopCh)
+			cached.cancel = cancel
+			// Start the source in the background so that a slow to sync source cannot block other volumes. Until the
+			// source has started, sources read directly from their backend.
+			go func() { _ = s.startSource(ctx, created, cached.volumes) }()
+		}
+
+		s.sources[src] = cached
+	}
+
+	cached.volumes[volumeID] = struct{}{}
+	s.volumeSources[volumeID] = src
+	return cached.Source, nil
+}
+
+// ReleaseVolume forgets that the volume references its source, stopping and evicting the source if no other volume
+// references it.
+func (s *volumeSource) ReleaseVolume(volumeID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.defaultVolumes, volumeID)
+	delete(s.changedVolumes, volumeID)
+
+	src, ok := s.volumeSources[volumeID]
+	if !ok {
+		return
+	}
+	delete(s.volumeSources, volumeID)
+
+	cached := s.sources[src]
+	delete(cached.volumes, volumeID)
+	if len(cached.volumes) > 0 {
+		return
+	}
+	if cached.cancel != nil {
+		cached.cancel()
+	}
+	delete(s.sources, src)
+}
+
+// ChangedVolumes returns the IDs of the volumes whose source has notified changes since it was last called.
+func (s *volumeSource) ChangedVolumes() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	volumeIDs := make([]string, 0, len(s.changedVolumes))
+	for volumeID := range s.changedVolumes {
+		volumeIDs = append(volumeIDs, volumeID)
+	}
+	sort.Strings(volumeIDs)
+	s.changedVolumes = map[string]struct{}{}
+	return volumeIDs
+}
+
+// startSource starts the source if required. Changes notified by the source are forwarded for the volumes, which must
+// only be accessed while holding the lock.
+func (s *volumeSource) startSource(ctx context.Context, src Source, volumes map[string]struct{}) error {
+	if r, ok := src.(Runnable); ok {
+		if err := r.Start(ctx); err != nil {
+			return err
+		}
+	}
+	if n, ok := src.(Notifier); ok {
+		go s.forwardChanges(ctx.Done(), n, volumes)
+	}
+	return nil
+}
+
+func (s *volumeSource) forwardChanges(stopCh <-chan struct{}, n Notifier, volumes map[string]struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-n.Changes():
+			s.lock.Lock()
+			for volumeID := range volumes {
+				s.changedVolumes[volumeID] = struct{}{}
+			}
+			s.lock.Unlock()
+			s.notify()
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 D2iQ, Inc. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package source
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	csiapi "github.com/d2iq-labs/csi-driver-trusted-ca/pkg/apis/v1alpha1"
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
+)
+
+func TestVolumeSource_GetFiles(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"team-*=test::*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultSource := staticSource{"default.crt": []byte("default")}
+	s := NewVolumeSource(NewFactory(nil), defaultSource, allowlist)
+	testFiles, err := testSource{}.GetFiles(context.Background(), metadata.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		want          map[string][]byte
+		wantErr       bool
+	}{{
+		name: "no source attribute uses default source",
+		volumeContext: map[string]string{
+			csiapi.K8sVolumeContextKeyPodNamespace: "team-a",
+		},
+		want: defaultSource,
+	}, {
+		name: "allowed source",
+		volumeContext: map[string]string{
+			csiapi.K8sVolumeContextKeyPodNamespace: "team-a",
+			csiapi.SourceKey:                       "test::anything",
+		},
+		want: testFiles,
+	}, {
+		name: "source not allowed in namespace",
+		volumeContext: map[string]string{
+			csiapi.K8sVolumeContextKeyPodNamespace: "other",
+			csiapi.SourceKey:                       "test::anything",
+		},
+		wantErr: true,
+	}, {
+		name: "source not allowed",
+		volumeContext: map[string]string{
+			csiapi.K8sVolumeContextKeyPodNamespace: "team-a",
+			csiapi.SourceKey:                       "configmap::kube-system/ca-certs",
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetFiles(context.Background(), metadata.Metadata{VolumeContext: tt.volumeContext})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVolumeSource_EvictsUnusedSources(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	started := make(chan context.Context, 1)
+	defer func(getter func(string) (Source, error)) { getters["runnable"] = getter }(getters["runnable"])
+	getters["runnable"] = func(string) (Source, error) { return runnableSource{started: started}, nil }
+
+	allowlist, err := ParseAllowlist([]string{"*=runnable::*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewVolumeSource(NewFactory(nil), staticSource{}, allowlist).(*volumeSource)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, volumeID := range []string{"vol-1", "vol-2"} {
+		_, err := s.GetFiles(ctx, metadata.Metadata{
+			VolumeID:      volumeID,
+			VolumeContext: map[string]string{csiapi.SourceKey: "runnable::a"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var srcCtx context.Context
+	select {
+	case srcCtx = <-started:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the source to start")
+	}
+
+	s.ReleaseVolume("vol-1")
+	if srcCtx.Err() != nil {
+		t.Error("expected the source to keep running while a volume references it")
+	}
+	if len(s.sources) != 1 {
+		t.Errorf("expected the source to stay cached while a volume references it but got %d sources", len(s.sources))
+	}
+
+	s.ReleaseVolume("vol-2")
+	if srcCtx.Err() == nil {
+		t.Error("expected the source to be stopped once no volume references it")
+	}
+	if len(s.sources) != 0 {
+		t.Errorf("expected the source to be evicted once no volume references it but got %d sources", len(s.sources))
+	}
+}
+
+// runnableSource sends the context it has been started with to started.
+type runnableSource struct {
+	staticSource
+	started chan<- context.Context
+}
+
+func (s runnableSource) Start(ctx context.Context) error {
+	s.started <- ctx
+	return nil
+}
+
+func TestVolumeSource_ChangedVolumes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var lock sync.Mutex
+	created := map[string]*notifyingSource{}
+	defer func(getter func(string) (Source, error)) { getters["notifying"] = getter }(getters["notifying"])
+	getters["notifying"] = func(cfg string) (Source, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		created[cfg] = &notifyingSource{}
+		return created[cfg], nil
+	}
+
+	allowlist, err := ParseAllowlist([]string{"*=notifying::*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultSource := &notifyingSource{}
+	s := NewVolumeSource(NewFactory(nil), defaultSource, allowlist).(*volumeSource)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for volumeID, src := range map[string]string{"vol-a1": "a", "vol-a2": "a", "vol-b": "b", "vol-default": ""} {
+		meta := metadata.Metadata{VolumeID: volumeID}
+		if src != "" {
+			meta.VolumeContext = map[string]string{csiapi.SourceKey: "notifying::" + src}
+		}
+		if _, err := s.GetFiles(ctx, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		source func() *notifyingSource
+		want   []string
+	}{{
+		name:   "selected source",
+		source: func() *notifyingSource { lock.Lock(); defer lock.Unlock(); return created["a"] },
+		want:   []string{"vol-a1", "vol-a2"},
+	}, {
+		name:   "default source",
+		source: func() *notifyingSource { return defaultSource },
+		want:   []string{"vol-default"},
+	}}
+	for _, tt := range tests {
+		// Sources selected by volumes are started in the background, so notify until the change is forwarded.
+		var got []string
+		for len(got) == 0 && ctx.Err() == nil {
+			tt.source().notify()
+			select {
+			case <-s.Changes():
+				got = s.ChangedVolumes()
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected changed volumes %q but got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+// notifyingSource notifies changes when notify is called.
+type notifyingSource struct {
+	changeNotifier
+	staticSource
+}
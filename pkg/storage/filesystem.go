@@ -48,6 +48,10 @@ type Filesystem struct {
 	// the value. Attribute value must be a valid int64 value.
 	// If FixedFSGroup is defined, this field has no effect.
 	FSGroupVolumeAttributeKey string
+
+	// LinuxTLS configures the files generated in each volume from the trusted
+	// CA certificates, e.g. Java truststores.
+	LinuxTLS linuxtls.Options
 }
 
 // Ensure the Filesystem implementation is fully featured.
@@ -239,7 +243,10 @@ func (f *Filesystem) WriteFiles(meta metadata.Metadata, files map[string][]byte)
 	}
 
 	payload := makePayload(files)
-	dirFuncs := linuxtls.DirFuncsForVolume(meta)
+	dirFuncs, err := linuxtls.DirFuncsForVolume(meta, f.LinuxTLS)
+	if err != nil {
+		return err
+	}
 	if err := writer.Write(payload, dirFuncs...); err != nil {
 		return err
 	}
@@ -4,11 +4,24 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"testing/fstest"
+	"time"
 
+	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/linuxtls"
 	"github.com/d2iq-labs/csi-driver-trusted-ca/pkg/metadata"
 )
 
@@ -220,3 +233,95 @@ func Test_fsGroupForMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestFilesystem_WriteFiles_NestedFiles(t *testing.T) {
+	caA, caB := newTestCA(t, "a"), newTestCA(t, "b")
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+	}{{
+		name:  "top-level files",
+		files: map[string][]byte{"a.pem": caA, "b.crt": caB},
+	}, {
+		name:  "hostpath subdirectories",
+		files: map[string][]byte{"a.pem": caA, "corp/issuing/b.crt": caB},
+	}, {
+		name:  "selector namespace and name directories",
+		files: map[string][]byte{"pki/corp-ca/ca.crt": caA, "team/root-ca/ca.crt": caB},
+	}, {
+		name:  "item target paths",
+		files: map[string][]byte{"certs/corp.pem": caA, "issuer/ca.pem": caB},
+	}}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &Filesystem{baseDir: t.TempDir()}
+			meta := metadata.Metadata{VolumeID: "fake-volume"}
+			// Create the data directory writable for tests not running as root.
+			dataDir := backend.dataPathForVolumeID(meta.VolumeID)
+			if err := os.MkdirAll(dataDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := backend.WriteFiles(meta, tt.files); err != nil {
+				t.Fatal(err)
+			}
+
+			bundle, err := os.ReadFile(filepath.Join(dataDir, linuxtls.DefaultBundleFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var subjects []string
+			for rest := bundle; ; {
+				var block *pem.Block
+				block, rest = pem.Decode(rest)
+				if block == nil {
+					break
+				}
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				subjects = append(subjects, cert.Subject.String())
+
+				// Each certificate is reachable via its subject hash link.
+				hash, err := linuxtls.SubjectHash(cert)
+				if err != nil {
+					t.Fatal(err)
+				}
+				linked, err := os.ReadFile(filepath.Join(dataDir, fmt.Sprintf("%08x.0", hash)))
+				if err != nil {
+					t.Errorf("expected a subject hash link for %q: %v", cert.Subject, err)
+				} else if block, _ := pem.Decode(linked); block == nil || !bytes.Equal(block.Bytes, cert.Raw) {
+					t.Errorf("expected the subject hash link for %q to resolve to the certificate", cert.Subject)
+				}
+			}
+			if want := []string{"CN=a", "CN=b"}; !reflect.DeepEqual(subjects, want) {
+				t.Errorf("expected certificates %v in bundle but got %v", want, subjects)
+			}
+		})
+	}
+}
+
+func newTestCA(t *testing.T, cn string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -55,8 +55,10 @@ var _ = Describe("Successful",
 				Expect(err).NotTo(HaveOccurred())
 			}
 
-			By("Successfully create configmap with dummy data")
-			var err error
+			caBytes, err := os.ReadFile(e2eConfig.Registry.CACertFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Successfully create configmap with data using registry CA certificate")
 			cm, err = kindClusterClient.CoreV1().ConfigMaps(corev1.NamespaceDefault).Create(
 				ctx,
 				&corev1.ConfigMap{
@@ -64,15 +66,13 @@ var _ = Describe("Successful",
 						Namespace:    corev1.NamespaceDefault,
 						GenerateName: "trusted-certs-",
 					},
-					Data: map[string]string{"c": "d"},
+					Data: map[string]string{"c": string(caBytes)},
 				},
 				metav1.CreateOptions{},
 			)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Successfully create secret with data using registry CA certificate")
-			caBytes, err := os.ReadFile(e2eConfig.Registry.CACertFile)
-			Expect(err).NotTo(HaveOccurred())
 			secret, err = kindClusterClient.CoreV1().Secrets(corev1.NamespaceDefault).Create(
 				ctx,
 				&corev1.Secret{
@@ -120,21 +120,27 @@ var _ = Describe("Successful",
 				kindClusterClient,
 				kindClusterRESTConfig,
 				pod.Namespace, pod.Name, "container",
-				"/etc/ssl/certs/a",
+				"/etc/ssl/certs/ca.crt",
 			)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(stderr).To(BeEmpty())
-			Expect(contents).To(Equal("b"))
+			Expect(contents).To(HavePrefix("-----BEGIN CERTIFICATE-----"))
 		})
 
-		reconfigureCSIDriver := func(ctx context.Context, src string) {
+		reconfigureCSIDriver := func(ctx context.Context, src string, extraValues ...map[string]interface{}) {
+			values := map[string]interface{}{
+				"trustedCertsSource": src,
+			}
+			for _, v := range extraValues {
+				for k, val := range v {
+					values[k] = val
+				}
+			}
 			release, err := helm.InstallOrUpgrade(
 				ctx,
 				"csi-driver-trusted-ca",
 				filepath.Join("..", "..", "..", "..", "charts", "csi-driver"),
-				map[string]interface{}{
-					"trustedCertsSource": src,
-				},
+				values,
 				e2eConfig.Kubeconfig,
 				metav1.NamespaceSystem,
 				GinkgoWriter.Printf,
@@ -195,7 +201,7 @@ var _ = Describe("Successful",
 				)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(stderr).To(BeEmpty())
-				Expect(contents).To(Equal("d"))
+				Expect(contents).To(Equal(cm.Data["c"]))
 			})
 		})
 
@@ -299,6 +305,11 @@ var _ = Describe("Successful",
 					reconfigureCSIDriver(
 						ctx,
 						fmt.Sprintf("oci::%s", ociAddress),
+						map[string]interface{}{
+							"oci": map[string]interface{}{
+								"insecureRegistries": []string{e2eConfig.Registry.Address},
+							},
+						},
 					)
 				},
 			)
@@ -334,6 +345,59 @@ var _ = Describe("Successful",
 					Expect(err).NotTo(HaveOccurred(), "stdout: %s, stderr: %s", stdout, stderr)
 				},
 			)
+
+			It(
+				"Reconfigure trusted CA CSI driver daemonset to use OCI source verifying the registry CA",
+				func(ctx SpecContext) {
+					caBytes, err := os.ReadFile(e2eConfig.Registry.CACertFile)
+					Expect(err).NotTo(HaveOccurred())
+
+					ociCA, err := kindClusterClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).Create(
+						ctx,
+						&corev1.ConfigMap{
+							ObjectMeta: metav1.ObjectMeta{
+								Namespace:    metav1.NamespaceSystem,
+								GenerateName: "oci-registry-ca-",
+							},
+							Data: map[string]string{"ca.crt": string(caBytes)},
+						},
+						metav1.CreateOptions{},
+					)
+					Expect(err).NotTo(HaveOccurred())
+
+					ociAddress := fmt.Sprintf(
+						"%s/%s:%s",
+						e2eConfig.Registry.Address,
+						"trusted-certs",
+						"v1",
+					)
+					reconfigureCSIDriver(
+						ctx,
+						fmt.Sprintf("oci::%s", ociAddress),
+						map[string]interface{}{
+							"oci": map[string]interface{}{
+								"caConfigMap": ociCA.Name,
+							},
+						},
+					)
+				},
+			)
+
+			It(
+				"Test curl without specifying CA certs on Alpine with certs from OCI registry verified via CA",
+				func(ctx SpecContext) {
+					pod := runTestPodInNewNamespace(ctx, kindClusterClient, "alpine")
+
+					stdout, stderr, err := kubernetes.ExecuteInPod(
+						ctx,
+						kindClusterClient,
+						kindClusterRESTConfig,
+						pod.Namespace, pod.Name, "container",
+						"curl", "-fsSL", fmt.Sprintf("https://%s", e2eConfig.Registry.Address),
+					)
+					Expect(err).NotTo(HaveOccurred(), "stdout: %s, stderr: %s", stdout, stderr)
+				},
+			)
 		})
 	},
 )